@@ -14,7 +14,6 @@ package net
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"internal/bytealg"
 	"syscall"
@@ -25,6 +24,12 @@ const (
 	// defaultTCPKeepAlive is a default constant value for TCPKeepAlive times
 	// See go.dev/issue/31510
 	defaultTCPKeepAlive = 15 * time.Second
+
+	// For the code review convenience, we keep those default values here
+	// for the keep-alive options.
+	defaultTCPKeepAliveIdle     = 15 * time.Second
+	defaultTCPKeepAliveInterval = 15 * time.Second
+	defaultTCPKeepAliveCount    = 9
 )
 
 // mptcpStatus is a tristate for Multipath TCP, see go.dev/issue/56539
@@ -87,6 +92,67 @@ type Dialer struct {
 	// If KeepAliveConfig.Enable is false and KeepAlive is negative,
 	// keep-alive probes are disabled.
 	KeepAliveConfig KeepAliveConfig
+
+	// If Control is not nil, it is called after creating the network
+	// connection but before actually dialing.
+	//
+	// Network and address parameters passed to Control function are not
+	// necessarily the ones passed to Dial. Calling Dial with TCP networks
+	// will cause the Control function to be called with "tcp4" or "tcp6",
+	// UDP networks become "udp4" or "udp6", IP networks become "ip4" or "ip6",
+	// and other known networks are passed as-is.
+	//
+	// Control is ignored if ControlContext is not nil.
+	//
+	// TINYGO: The fd passed to the RawConn callbacks is the netdev socket.
+	Control func(network, address string, c syscall.RawConn) error
+
+	// If ControlContext is not nil, it is called after creating the network
+	// connection but before actually dialing.
+	//
+	// Network and address parameters passed to ControlContext function are not
+	// necessarily the ones passed to Dial. Calling Dial with TCP networks
+	// will cause the ControlContext function to be called with "tcp4" or "tcp6",
+	// UDP networks become "udp4" or "udp6", IP networks become "ip4" or "ip6",
+	// and other known networks are passed as-is.
+	//
+	// If ControlContext is not nil, Control is ignored.
+	ControlContext func(ctx context.Context, network, address string, c syscall.RawConn) error
+
+	// TINYGO: TLSConfig configures the TLS of connections dialed on the
+	// "tls" network.  If nil, the netdev's defaults are used, like
+	// DialTLS.
+	TLSConfig *TLSConfig
+
+	// TINYGO: Interface, if not empty, names the network interface to
+	// dial from, on netdevs with several interfaces; see UseFailover.
+	Interface string
+}
+
+func minNonzeroTime(a, b time.Time) time.Time {
+	if a.IsZero() {
+		return b
+	}
+	if b.IsZero() || a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// deadline returns the earliest of:
+//   - now+Timeout
+//   - d.Deadline
+//   - the context's deadline
+//
+// Or zero, if none of Timeout, Deadline, or context's deadline is set.
+func (d *Dialer) deadline(ctx context.Context, now time.Time) (earliest time.Time) {
+	if d.Timeout != 0 { // including negative, for historical reasons
+		earliest = now.Add(d.Timeout)
+	}
+	if d, ok := ctx.Deadline(); ok {
+		earliest = minNonzeroTime(earliest, d)
+	}
+	return minNonzeroTime(earliest, d.Deadline)
 }
 
 // Dial connects to the address on the named network.
@@ -94,8 +160,11 @@ type Dialer struct {
 // See Go "net" package Dial() for more information.
 //
 // Note: Tinygo Dial supports a subset of networks supported by Go Dial,
-// specifically: "tcp", "tcp4", "udp", and "udp4".  IP and unix networks are
-// not supported.
+// specifically: "tcp", "tcp4", "udp", "udp4", "ip:proto"/"ip4:proto"
+// (e.g. "ip4:icmp") if the netdev supports raw sockets, the in-memory
+// unix networks "unix", "unixgram" and "unixpacket", and "tls" (see
+// DialTLS).  IPv6 networks are not supported.  Dials to other than the unix
+// networks fail with ErrLinkDown while the netdev reports its link down.
 func Dial(network, address string) (Conn, error) {
 	var d Dialer
 	return d.Dial(network, address)
@@ -147,21 +216,75 @@ func (d *Dialer) Dial(network, address string) (Conn, error) {
 // parameters.
 func (d *Dialer) DialContext(ctx context.Context, network, address string) (Conn, error) {
 
-	// TINYGO: Ignoring context
+	// TINYGO: Ignoring context, other than for the socket trace and the
+	// TINYGO: "tls" network
+
+	trace := socketTrace(ctx)
+
+	// TINYGO: Fail fast while the netdev reports its link down
+
+	switch network {
+	case "unix", "unixgram", "unixpacket":
+	default:
+		if err := checkLink(); err != nil {
+			return nil, &OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
+		}
+	}
 
 	switch network {
 	case "tcp", "tcp4":
-		raddr, err := ResolveTCPAddr(network, address)
+		raddr, err := resolveTCPAddr(trace, network, address)
 		if err != nil {
 			return nil, err
 		}
-		return DialTCP(network, nil, raddr)
+		laddr, ok := d.LocalAddr.(*TCPAddr)
+		if d.LocalAddr != nil && !ok {
+			return nil, &OpError{Op: "dial", Net: network, Source: d.LocalAddr, Addr: raddr, Err: &AddrError{Err: "mismatched local address type", Addr: d.LocalAddr.String()}}
+		}
+		return dialTCP(trace, d.control(ctx, network), network, laddr, raddr)
 	case "udp", "udp4":
-		raddr, err := ResolveUDPAddr(network, address)
+		raddr, err := resolveUDPAddr(trace, network, address)
 		if err != nil {
 			return nil, err
 		}
-		return DialUDP(network, nil, raddr)
+		laddr, ok := d.LocalAddr.(*UDPAddr)
+		if d.LocalAddr != nil && !ok {
+			return nil, &OpError{Op: "dial", Net: network, Source: d.LocalAddr, Addr: raddr, Err: &AddrError{Err: "mismatched local address type", Addr: d.LocalAddr.String()}}
+		}
+		if laddr != nil {
+			// dialUDP picks the port of a zero port laddr
+			la := *laddr
+			laddr = &la
+		}
+		return dialUDP(trace, d.control(ctx, network), network, laddr, raddr)
+	case "tls":
+		c, err := d.dialTLS(ctx, trace, address)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	case "unix", "unixgram", "unixpacket":
+		raddr, err := ResolveUnixAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+		c, err := DialUnix(network, nil, raddr)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	if afnet, _, err := parseNetwork(ctx, network, true); err == nil && (afnet == "ip" || afnet == "ip4") {
+		raddr, err := resolveIPAddr(ctx, trace, network, address)
+		if err != nil {
+			return nil, err
+		}
+		c, err := dialIP(ctx, trace, d.control(ctx, afnet), network, nil, raddr)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
 	}
 
 	return nil, fmt.Errorf("Network %s not supported", network)
@@ -199,6 +322,15 @@ type ListenConfig struct {
 	// keep-alive probes are disabled.
 	KeepAliveConfig KeepAliveConfig
 
+	// TINYGO: Backlog is the maximum length of the queue of pending
+	// connections passed to netdev Listen.  If zero, a backlog of 5 is
+	// used.
+	Backlog int
+
+	// TINYGO: Interface, if not empty, names the network interface to
+	// listen on, on netdevs with several interfaces; see UseFailover.
+	Interface string
+
 	// If mptcpStatus is set to a value allowing Multipath TCP (MPTCP) to be
 	// used, any call to Listen with "tcp(4|6)" as network will use MPTCP if
 	// supported by the operating system.
@@ -213,7 +345,46 @@ type ListenConfig struct {
 // The ctx argument is used while resolving the address on which to listen;
 // it does not affect the returned Listener.
 func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (Listener, error) {
-	return nil, errors.New("dial:ListenConfig:Listen not implemented")
+
+	// TINYGO: Only TCP and in-memory Unix, and ctx is only used for the
+	// socket trace
+
+	backlog := lc.Backlog
+	if backlog <= 0 {
+		backlog = defaultBacklog
+	}
+
+	switch network {
+	case "tcp", "tcp4":
+	case "unix", "unixpacket":
+		laddr, err := ResolveUnixAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+		if laddr.Name == "" {
+			return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: errMissingAddress}
+		}
+		ln, err := listenUnix(network, laddr, backlog)
+		if err != nil {
+			return nil, err
+		}
+		return ln, nil
+	default:
+		return nil, fmt.Errorf("Network %s not supported", network)
+	}
+
+	trace := socketTrace(ctx)
+
+	laddr, err := resolveTCPAddr(trace, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := listenTCP(trace, lc.control(network), network, laddr, backlog)
+	if err != nil {
+		return nil, err
+	}
+	return ln, nil
 }
 
 // ListenPacket announces on the local network address.
@@ -224,7 +395,39 @@ func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (Li
 // The ctx argument is used while resolving the address on which to listen;
 // it does not affect the returned PacketConn.
 func (lc *ListenConfig) ListenPacket(ctx context.Context, network, address string) (PacketConn, error) {
-	return nil, errors.New("dial:ListenConfig:ListenPacket not implemented")
+
+	// TINYGO: Only IP and in-memory Unix networks, and ctx is only used
+	// for the socket trace
+
+	if network == "unixgram" {
+		laddr, err := ResolveUnixAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+		c, err := ListenUnixgram(network, laddr)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	afnet, _, err := parseNetwork(ctx, network, true)
+	if err != nil || (afnet != "ip" && afnet != "ip4") {
+		return nil, fmt.Errorf("Network %s not supported", network)
+	}
+
+	trace := socketTrace(ctx)
+
+	laddr, err := resolveIPAddr(ctx, trace, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := listenIP(ctx, trace, lc.control(afnet), network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func parseNetwork(ctx context.Context, network string, needsProto bool) (afnet string, proto int, err error) {
@@ -264,20 +467,22 @@ func parseNetwork(ctx context.Context, network string, needsProto bool) (afnet s
 // See Go "net" package Listen() for more information.
 //
 // Note: Tinygo Listen supports a subset of networks supported by Go Listen,
-// specifically: "tcp", "tcp4".  "tcp6" and unix networks are not supported.
+// specifically: "tcp", "tcp4", and the in-memory unix networks "unix" and
+// "unixpacket".  "tcp6" is not supported.
 func Listen(network, address string) (Listener, error) {
+	var lc ListenConfig
+	return lc.Listen(context.Background(), network, address)
+}
 
-	//	println("Listen", address)
-	switch network {
-	case "tcp", "tcp4":
-	default:
-		return nil, fmt.Errorf("Network %s not supported", network)
-	}
-
-	laddr, err := ResolveTCPAddr(network, address)
-	if err != nil {
-		return nil, err
-	}
-
-	return listenTCP(laddr)
+// ListenPacket announces on the local network address.
+//
+// See Go "net" package ListenPacket() for more information.
+//
+// Note: Tinygo ListenPacket supports a subset of networks supported by Go
+// ListenPacket, specifically: "ip:proto" and "ip4:proto" (e.g. "ip4:icmp"),
+// if the netdev supports raw sockets, and the in-memory unix network
+// "unixgram".
+func ListenPacket(network, address string) (PacketConn, error) {
+	var lc ListenConfig
+	return lc.ListenPacket(context.Background(), network, address)
 }
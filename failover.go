@@ -0,0 +1,661 @@
+// Failover across several netdevs
+
+package net
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// FailoverInterface is one network interface of a failover netdev; see
+// UseFailover.
+type FailoverInterface struct {
+	// Name names the interface, e.g. "eth0" or "wlan0", for
+	// Dialer.Interface and ListenConfig.Interface.  Names must be unique.
+	Name string
+
+	// Netdev is the interface's netdev, as passed by the drivers to
+	// useNetdev.
+	Netdev any
+}
+
+// errSocketBound is returned when binding a socket to an interface after
+// it was bound or connected.
+var errSocketBound = errors.New("socket already bound")
+
+// failoverPollInterval is how long Poll waits on the netdev of one
+// interface before checking the others, when polling sockets of several.
+const failoverPollInterval = 10 * time.Millisecond
+
+// failoverNetdev is a netdever routing sockets across several netdevs, in
+// order of preference.  Sockets are created on the first healthy netdev
+// and stay on it until closed; sockets bound to a local address or
+// interface name move to the netdev of that interface.  The netdev's fds
+// are its own, each mapping to a socket of one of the netdevs.
+type failoverNetdev struct {
+	ifaces []*failoverIface
+
+	mu      sync.Mutex
+	sockets map[int]*failoverSocket
+	nextFD  int
+	notify  func(up bool, addr netip.Addr)
+}
+
+// failoverIface is an interface of a failoverNetdev.  up and addr are the
+// link state last reported by dev, if it is a linkNotifier.
+type failoverIface struct {
+	name     string
+	dev      netdever
+	notifies bool
+
+	up   bool // guarded by failoverNetdev.mu
+	addr netip.Addr
+}
+
+// failoverSocket is a socket of a failoverNetdev.  The arguments to Socket
+// and the options set are kept until the socket is bound or connected, to
+// recreate the socket on another interface.
+type failoverSocket struct {
+	iface  *failoverIface
+	fd     int
+	placed bool // bound, connected or accepted; the interface is final
+
+	domain, stype, protocol int
+	opts                    []failoverSockOpt
+}
+
+type failoverSockOpt struct {
+	level, opt int
+	value      any
+}
+
+// UseFailover makes the net package use several netdevs, e.g. Ethernet and
+// Wi-Fi, in place of the one netdev set by the drivers.  The interfaces are
+// in order of preference: new sockets go to the first interface whose link
+// is up, so the net package fails over to the next interface when the
+// link of the first goes down, and back when it comes up again.  Open
+// sockets stay on their interface.
+//
+// The link of an interface is known if its netdev reports link changes;
+// otherwise the interface is healthy while its netdev has an address.
+// OnLinkChange functions see the link up while any interface is, with the
+// address of the preferred one, so the address changes on failover.
+//
+// A socket is pinned to an interface by its name, with Dialer.Interface
+// or ListenConfig.Interface, or by binding it to the interface's address,
+// with Dialer.LocalAddr or by listening on that address.  Interfaces lists
+// the interfaces by these names, in order of preference.
+func UseFailover(ifaces ...FailoverInterface) error {
+	if len(ifaces) == 0 {
+		return errors.New("UseFailover: no interfaces")
+	}
+	f := &failoverNetdev{sockets: make(map[int]*failoverSocket)}
+	for _, fi := range ifaces {
+		dev, ok := fi.Netdev.(netdever)
+		if !ok {
+			return errors.New("UseFailover: " + fi.Name + ": not a netdev")
+		}
+		if fi.Name == "" || f.iface(fi.Name) != nil {
+			return errors.New("UseFailover: missing or duplicate interface name " + fi.Name)
+		}
+		iface := &failoverIface{name: fi.Name, dev: dev, up: true}
+		f.ifaces = append(f.ifaces, iface)
+	}
+	for _, iface := range f.ifaces {
+		if n, ok := iface.dev.(linkNotifier); ok {
+			iface.notifies = true
+			n.NotifyLink(func(up bool, addr netip.Addr) {
+				f.linkChanged(iface, up, addr)
+			})
+		}
+	}
+	useNetdev(f)
+	return nil
+}
+
+// iface returns the interface named name, or nil.
+func (f *failoverNetdev) iface(name string) *failoverIface {
+	for _, iface := range f.ifaces {
+		if iface.name == name {
+			return iface
+		}
+	}
+	return nil
+}
+
+// healthy reports whether new sockets may go to iface, and its address.
+func (f *failoverNetdev) healthy(iface *failoverIface) (bool, netip.Addr) {
+	if iface.notifies {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return iface.up, iface.addr
+	}
+	ip, err := iface.dev.Addr()
+	return err == nil && ip.IsValid(), ip
+}
+
+// addr returns the address of iface.
+func (f *failoverNetdev) addr(iface *failoverIface) netip.Addr {
+	f.mu.Lock()
+	addr := iface.addr
+	f.mu.Unlock()
+	if !addr.IsValid() {
+		addr, _ = iface.dev.Addr()
+	}
+	return addr
+}
+
+// preferred returns the first healthy interface and its address, or the
+// first interface if none is healthy.
+func (f *failoverNetdev) preferred() (iface *failoverIface, up bool, addr netip.Addr) {
+	for _, iface := range f.ifaces {
+		if up, addr := f.healthy(iface); up {
+			return iface, true, addr
+		}
+	}
+	return f.ifaces[0], false, netip.Addr{}
+}
+
+// linkChanged records a link change reported by the netdev of iface, and
+// reports the resulting link state of the failover netdev.
+func (f *failoverNetdev) linkChanged(iface *failoverIface, up bool, addr netip.Addr) {
+	f.mu.Lock()
+	iface.up, iface.addr = up, addr
+	notify := f.notify
+	f.mu.Unlock()
+	if notify != nil {
+		_, up, addr := f.preferred()
+		notify(up, addr)
+	}
+}
+
+// NotifyLink implements linkNotifier, reporting the link up while any
+// interface is healthy, with the address of the preferred one.
+func (f *failoverNetdev) NotifyLink(notify func(up bool, addr netip.Addr)) {
+	f.mu.Lock()
+	f.notify = notify
+	f.mu.Unlock()
+	_, up, addr := f.preferred()
+	notify(up, addr)
+}
+
+// lookup returns the interface and the interface's fd of socket fd.
+func (f *failoverNetdev) lookup(fd int) (*failoverIface, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.sockets[fd]
+	if !ok {
+		return nil, -1, ErrClosed
+	}
+	return s.iface, s.fd, nil
+}
+
+// fdNetdev implements fdNetdever.
+func (f *failoverNetdev) fdNetdev(fd int) netdever {
+	iface, _, err := f.lookup(fd)
+	if err != nil {
+		return nil
+	}
+	return iface.dev
+}
+
+// add adds the socket s, returning its fd.
+func (f *failoverNetdev) add(s *failoverSocket) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fd := f.nextFD
+	f.nextFD++
+	f.sockets[fd] = s
+	return fd
+}
+
+// place moves the socket fd to iface, unless iface is nil, and makes its
+// interface final.  Moving the socket recreates it on iface with the
+// options set so far.
+func (f *failoverNetdev) place(fd int, iface *failoverIface) error {
+	f.mu.Lock()
+	s, ok := f.sockets[fd]
+	if !ok {
+		f.mu.Unlock()
+		return ErrClosed
+	}
+	if iface == nil || iface == s.iface {
+		s.placed = true
+		f.mu.Unlock()
+		return nil
+	}
+	if s.placed {
+		f.mu.Unlock()
+		return errSocketBound
+	}
+	old, oldfd := s.iface, s.fd
+	domain, stype, protocol, opts := s.domain, s.stype, s.protocol, s.opts
+	f.mu.Unlock()
+
+	newfd, err := iface.dev.Socket(domain, stype, protocol)
+	if err != nil {
+		return err
+	}
+	for _, o := range opts {
+		if err := iface.dev.SetSockOpt(newfd, o.level, o.opt, o.value); err != nil {
+			iface.dev.Close(newfd)
+			return err
+		}
+	}
+
+	// The socket may have been closed, or placed, in the meantime
+	f.mu.Lock()
+	if f.sockets[fd] != s || s.placed || s.iface != old || s.fd != oldfd {
+		closed := f.sockets[fd] != s
+		f.mu.Unlock()
+		iface.dev.Close(newfd)
+		if closed {
+			return ErrClosed
+		}
+		return errSocketBound
+	}
+	s.iface, s.fd, s.placed, s.opts = iface, newfd, true, nil
+	f.mu.Unlock()
+	old.dev.Close(oldfd)
+	return nil
+}
+
+// GetHostByName resolves name with the first healthy interface able to.
+func (f *failoverNetdev) GetHostByName(name string) (netip.Addr, error) {
+	err := ErrNetdevNotSet
+	for _, iface := range f.ifaces {
+		if up, _ := f.healthy(iface); !up {
+			continue
+		}
+		var ip netip.Addr
+		if ip, err = iface.dev.GetHostByName(name); err == nil {
+			return ip, nil
+		}
+	}
+	if err == ErrNetdevNotSet {
+		return f.ifaces[0].dev.GetHostByName(name)
+	}
+	return netip.Addr{}, err
+}
+
+// Addr returns the address of the preferred interface.
+func (f *failoverNetdev) Addr() (netip.Addr, error) {
+	iface, up, addr := f.preferred()
+	if up && addr.IsValid() {
+		return addr, nil
+	}
+	return iface.dev.Addr()
+}
+
+func (f *failoverNetdev) Socket(domain int, stype int, protocol int) (int, error) {
+	iface, _, _ := f.preferred()
+	fd, err := iface.dev.Socket(domain, stype, protocol)
+	if err != nil {
+		return -1, err
+	}
+	return f.add(&failoverSocket{iface: iface, fd: fd, domain: domain, stype: stype, protocol: protocol}), nil
+}
+
+// Bind moves the socket to the interface having the address ip, if any.
+func (f *failoverNetdev) Bind(sockfd int, ip netip.AddrPort) error {
+	var target *failoverIface
+	if addr := ip.Addr().Unmap(); addr.IsValid() && !addr.IsUnspecified() {
+		for _, iface := range f.ifaces {
+			if f.addr(iface) == addr {
+				target = iface
+				break
+			}
+		}
+	}
+	if err := f.place(sockfd, target); err != nil {
+		return err
+	}
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return err
+	}
+	return iface.dev.Bind(fd, ip)
+}
+
+func (f *failoverNetdev) Connect(sockfd int, host string, ip netip.AddrPort) error {
+	if err := f.place(sockfd, nil); err != nil {
+		return err
+	}
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return err
+	}
+	return iface.dev.Connect(fd, host, ip)
+}
+
+func (f *failoverNetdev) Listen(sockfd int, backlog int) error {
+	if err := f.place(sockfd, nil); err != nil {
+		return err
+	}
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return err
+	}
+	return iface.dev.Listen(fd, backlog)
+}
+
+func (f *failoverNetdev) Accept(sockfd int) (int, netip.AddrPort, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, netip.AddrPort{}, err
+	}
+	newfd, raddr, err := iface.dev.Accept(fd)
+	if err != nil {
+		return -1, raddr, err
+	}
+	return f.add(&failoverSocket{iface: iface, fd: newfd, placed: true}), raddr, nil
+}
+
+func (f *failoverNetdev) Send(sockfd int, buf []byte, flags int, deadline time.Time) (int, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, err
+	}
+	return iface.dev.Send(fd, buf, flags, deadline)
+}
+
+func (f *failoverNetdev) Recv(sockfd int, buf []byte, flags int, deadline time.Time) (int, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, err
+	}
+	return iface.dev.Recv(fd, buf, flags, deadline)
+}
+
+func (f *failoverNetdev) Close(sockfd int) error {
+	f.mu.Lock()
+	s, ok := f.sockets[sockfd]
+	if !ok {
+		f.mu.Unlock()
+		return ErrClosed
+	}
+	delete(f.sockets, sockfd)
+	iface, fd := s.iface, s.fd
+	f.mu.Unlock()
+	return iface.dev.Close(fd)
+}
+
+// SetSockOpt binds the socket to an interface for SO_BINDTODEVICE.  Other
+// options are passed on, and kept to be set again if the socket moves.
+func (f *failoverNetdev) SetSockOpt(sockfd int, level int, opt int, value interface{}) error {
+	if level == _SOL_SOCKET && opt == _SO_BINDTODEVICE {
+		name, _ := value.(string)
+		iface := f.iface(name)
+		if iface == nil {
+			return errNoSuchInterface
+		}
+		return f.place(sockfd, iface)
+	}
+	f.mu.Lock()
+	s, ok := f.sockets[sockfd]
+	if !ok {
+		f.mu.Unlock()
+		return ErrClosed
+	}
+	if !s.placed {
+		s.opts = append(s.opts, failoverSockOpt{level, opt, value})
+	}
+	iface, fd := s.iface, s.fd
+	f.mu.Unlock()
+	return iface.dev.SetSockOpt(fd, level, opt, value)
+}
+
+// The optional extensions are passed on to the interface of the socket.
+// The net package only uses them, through netdevExt, on sockets whose
+// interface supports them.
+
+func (f *failoverNetdev) GetSockOpt(sockfd int, level int, opt int) (interface{}, error) {
+	if level == _SOL_SOCKET && opt == _SO_BINDTODEVICE {
+		iface, _, err := f.lookup(sockfd)
+		if err != nil {
+			return nil, err
+		}
+		return iface.name, nil
+	}
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return nil, err
+	}
+	g, ok := iface.dev.(sockOptGetter)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return g.GetSockOpt(fd, level, opt)
+}
+
+func (f *failoverNetdev) AcceptDeadline(sockfd int, deadline time.Time) (int, netip.AddrPort, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, netip.AddrPort{}, err
+	}
+	ad, ok := iface.dev.(acceptDeadliner)
+	if !ok {
+		return -1, netip.AddrPort{}, errors.ErrUnsupported
+	}
+	newfd, raddr, err := ad.AcceptDeadline(fd, deadline)
+	if err != nil {
+		return -1, raddr, err
+	}
+	return f.add(&failoverSocket{iface: iface, fd: newfd, placed: true}), raddr, nil
+}
+
+func (f *failoverNetdev) Shutdown(sockfd int, how int) error {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return err
+	}
+	if s, ok := iface.dev.(shutdowner); ok {
+		return s.Shutdown(fd, how)
+	}
+	return errors.ErrUnsupported
+}
+
+func (f *failoverNetdev) SendBuffers(sockfd int, bufs [][]byte, flags int, deadline time.Time) (int, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, err
+	}
+	if bs, ok := iface.dev.(buffersSender); ok {
+		return bs.SendBuffers(fd, bufs, flags, deadline)
+	}
+	return -1, errors.ErrUnsupported
+}
+
+func (f *failoverNetdev) RecvBuffer(sockfd int, flags int, deadline time.Time, consume func(b []byte) (int, error)) (int, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, err
+	}
+	if br, ok := iface.dev.(bufferReceiver); ok {
+		return br.RecvBuffer(fd, flags, deadline, consume)
+	}
+	return -1, errors.ErrUnsupported
+}
+
+func (f *failoverNetdev) RecvFrom(sockfd int, buf []byte, flags int, deadline time.Time) (int, netip.AddrPort, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, netip.AddrPort{}, err
+	}
+	if pn, ok := iface.dev.(packetNetdever); ok {
+		return pn.RecvFrom(fd, buf, flags, deadline)
+	}
+	return -1, netip.AddrPort{}, errors.ErrUnsupported
+}
+
+func (f *failoverNetdev) SendTo(sockfd int, buf []byte, flags int, to netip.AddrPort, deadline time.Time) (int, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return -1, err
+	}
+	if pn, ok := iface.dev.(packetNetdever); ok {
+		return pn.SendTo(fd, buf, flags, to, deadline)
+	}
+	return -1, errors.ErrUnsupported
+}
+
+func (f *failoverNetdev) TLSHandshake(sockfd int, deadline time.Time) error {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return err
+	}
+	if hs, ok := iface.dev.(tlsHandshaker); ok {
+		return hs.TLSHandshake(fd, deadline)
+	}
+	return errors.ErrUnsupported
+}
+
+func (f *failoverNetdev) TLSState(sockfd int) (TLSConnectionState, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return TLSConnectionState{}, err
+	}
+	if s, ok := iface.dev.(tlsStater); ok {
+		return s.TLSState(fd)
+	}
+	return TLSConnectionState{}, errors.ErrUnsupported
+}
+
+func (f *failoverNetdev) GetSockName(sockfd int) (netip.AddrPort, error) {
+	iface, fd, err := f.lookup(sockfd)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if sn, ok := iface.dev.(sockNamer); ok {
+		return sn.GetSockName(fd)
+	}
+	return netip.AddrPort{}, errors.ErrUnsupported
+}
+
+// Interfaces describes each interface as one, named as given to
+// UseFailover, with the MTU, hardware address and flags of the first
+// interface its netdev describes.  FlagRunning is set on healthy ones.
+func (f *failoverNetdev) Interfaces() ([]Interface, error) {
+	ift := make([]Interface, 0, len(f.ifaces))
+	for i, iface := range f.ifaces {
+		devift, err := devInterfaces(iface.dev)
+		if err != nil {
+			return nil, err
+		}
+		ifi := Interface{MTU: netdevMTU, Flags: FlagUp}
+		if len(devift) > 0 {
+			ifi = devift[0]
+		}
+		ifi.Index, ifi.Name = i+1, iface.name
+		ifi.Flags &^= FlagRunning
+		if up, _ := f.healthy(iface); up {
+			ifi.Flags |= FlagRunning
+		}
+		ift = append(ift, ifi)
+	}
+	return ift, nil
+}
+
+// InterfaceAddrs returns the addresses of the first interface the netdev of
+// the interface with index describes.
+func (f *failoverNetdev) InterfaceAddrs(index int) ([]netip.Prefix, []netip.Addr, error) {
+	if index < 1 || index > len(f.ifaces) {
+		return nil, nil, errNoSuchInterface
+	}
+	dev := f.ifaces[index-1].dev
+	devift, err := devInterfaces(dev)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(devift) == 0 {
+		return nil, nil, nil
+	}
+	return devInterfaceAddrs(dev, devift[0].Index)
+}
+
+// Ping pings with the preferred interface.
+func (f *failoverNetdev) Ping(ip netip.Addr, size int, deadline time.Time) (time.Duration, int, error) {
+	iface, _, _ := f.preferred()
+	if p, ok := iface.dev.(netdevPinger); ok {
+		return p.Ping(ip, size, deadline)
+	}
+	return 0, 0, errors.ErrUnsupported
+}
+
+// Poll polls the sockets of each interface with the interface's netdev.
+// With sockets on several interfaces, the interfaces are polled in turn,
+// waiting up to failoverPollInterval on each.
+func (f *failoverNetdev) Poll(fds []int, events []int, revents []int, deadline time.Time) (int, error) {
+	type group struct {
+		poller  netdevPoller
+		index   []int // of the fds in fds
+		fds     []int
+		events  []int
+		revents []int
+	}
+	var groups []*group
+	byIface := make(map[*failoverIface]*group)
+	for i, sockfd := range fds {
+		iface, fd, err := f.lookup(sockfd)
+		if err != nil {
+			revents[i] = _POLLERR
+			continue
+		}
+		g, ok := byIface[iface]
+		if !ok {
+			np, ok := iface.dev.(netdevPoller)
+			if !ok {
+				return 0, errors.ErrUnsupported
+			}
+			g = &group{poller: np}
+			byIface[iface] = g
+			groups = append(groups, g)
+		}
+		g.index = append(g.index, i)
+		g.fds = append(g.fds, fd)
+		g.events = append(g.events, events[i])
+		g.revents = append(g.revents, 0)
+	}
+
+	for {
+		ready := 0
+		for i := range revents {
+			if revents[i] != 0 {
+				ready++
+			}
+		}
+		for _, g := range groups {
+			d := deadline
+			if ready > 0 {
+				// Only collect what is ready already
+				d = time.Now()
+			} else if len(groups) > 1 {
+				d = time.Now().Add(failoverPollInterval)
+				if !deadline.IsZero() && deadline.Before(d) {
+					d = deadline
+				}
+			}
+			n, err := g.poller.Poll(g.fds, g.events, g.revents, d)
+			if err != nil {
+				return 0, err
+			}
+			if n == 0 {
+				continue
+			}
+			for j, i := range g.index {
+				if g.revents[j] != 0 {
+					revents[i] = g.revents[j]
+					ready++
+				}
+			}
+		}
+		if ready > 0 || len(groups) == 0 {
+			return ready, nil
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, nil
+		}
+	}
+}
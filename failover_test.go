@@ -0,0 +1,343 @@
+// Failover netdev tests
+
+package net
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ifaceNetdev is the netdev of one interface of a failover netdev.  It
+// records what is done to its sockets.
+type ifaceNetdev struct {
+	nopNetdev
+
+	mu      sync.Mutex
+	addr    netip.Addr
+	nextFD  int
+	sockets map[int]*ifaceSocket
+	ready   map[int]int // events ready per fd, for Poll
+	closes  int         // Close calls on closed or unknown fds
+}
+
+type ifaceSocket struct {
+	opts      map[int]any
+	bound     netip.AddrPort
+	connected bool
+	closed    bool
+}
+
+func newIfaceNetdev(addr string) *ifaceNetdev {
+	d := &ifaceNetdev{
+		nextFD:  1,
+		sockets: make(map[int]*ifaceSocket),
+		ready:   make(map[int]int),
+	}
+	if addr != "" {
+		d.addr = netip.MustParseAddr(addr)
+	}
+	return d
+}
+
+func (d *ifaceNetdev) setAddr(addr string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addr = netip.Addr{}
+	if addr != "" {
+		d.addr = netip.MustParseAddr(addr)
+	}
+}
+
+func (d *ifaceNetdev) socket(fd int) *ifaceSocket {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sockets[fd]
+}
+
+// open returns the number of sockets not closed, and of bad Close calls
+func (d *ifaceNetdev) open() (open, badCloses int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.sockets {
+		if !s.closed {
+			open++
+		}
+	}
+	return open, d.closes
+}
+
+func (d *ifaceNetdev) Addr() (netip.Addr, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.addr, nil
+}
+
+func (d *ifaceNetdev) Socket(domain int, stype int, protocol int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fd := d.nextFD
+	d.nextFD++
+	d.sockets[fd] = &ifaceSocket{opts: make(map[int]any)}
+	return fd, nil
+}
+
+func (d *ifaceNetdev) Bind(sockfd int, ip netip.AddrPort) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sockets[sockfd].bound = ip
+	return nil
+}
+
+func (d *ifaceNetdev) Connect(sockfd int, host string, ip netip.AddrPort) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sockets[sockfd].connected = true
+	return nil
+}
+
+func (d *ifaceNetdev) Close(sockfd int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.sockets[sockfd]
+	if !ok || s.closed {
+		d.closes++
+		return ErrClosed
+	}
+	s.closed = true
+	return nil
+}
+
+func (d *ifaceNetdev) SetSockOpt(sockfd int, level int, opt int, value interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sockets[sockfd].opts[opt] = value
+	return nil
+}
+
+func (d *ifaceNetdev) Poll(fds []int, events []int, revents []int, deadline time.Time) (int, error) {
+	d.mu.Lock()
+	n := 0
+	for i, fd := range fds {
+		revents[i] = d.ready[fd] & (events[i] | _POLLERR | _POLLHUP)
+		if revents[i] != 0 {
+			n++
+		}
+	}
+	d.mu.Unlock()
+	if n == 0 {
+		time.Sleep(time.Until(deadline))
+	}
+	return n, nil
+}
+
+// withFailover makes the net package use a failover netdev over devs,
+// named eth0, wlan0, ... in order, until the test ends.
+func withFailover(t *testing.T, devs ...*ifaceNetdev) *failoverNetdev {
+	oldNetdev := netdev
+	oldUp, oldAddr := LinkState()
+	t.Cleanup(func() {
+		netdev = oldNetdev
+		linkMu.Lock()
+		linkUp, linkAddr = oldUp, oldAddr
+		linkMu.Unlock()
+	})
+	var ifaces []FailoverInterface
+	for i, dev := range devs {
+		ifaces = append(ifaces, FailoverInterface{Name: []string{"eth0", "wlan0", "lte0"}[i], Netdev: dev})
+	}
+	if err := UseFailover(ifaces...); err != nil {
+		t.Fatal(err)
+	}
+	return netdev.(*failoverNetdev)
+}
+
+func TestUseFailoverErrors(t *testing.T) {
+	dev := newIfaceNetdev("10.0.0.2")
+	for _, ifaces := range [][]FailoverInterface{
+		nil,
+		{{Name: "eth0", Netdev: 42}},
+		{{Name: "", Netdev: dev}},
+		{{Name: "eth0", Netdev: dev}, {Name: "eth0", Netdev: dev}},
+	} {
+		if err := UseFailover(ifaces...); err == nil {
+			t.Errorf("UseFailover(%v) succeeded", ifaces)
+		}
+	}
+}
+
+func TestFailoverSocketPlacement(t *testing.T) {
+	eth, wlan := newIfaceNetdev(""), newIfaceNetdev("10.0.1.2")
+	f := withFailover(t, eth, wlan)
+
+	// eth0 has no address, so new sockets go to wlan0
+	fd1, err := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.fdNetdev(fd1) != wlan {
+		t.Errorf("socket created on %v, want wlan0", f.fdNetdev(fd1))
+	}
+	if ip, _ := f.Addr(); ip != netip.MustParseAddr("10.0.1.2") {
+		t.Errorf("Addr() = %v, want the address of wlan0", ip)
+	}
+
+	// Once eth0 is up, new sockets go back to it; open ones stay
+	eth.setAddr("10.0.0.2")
+	fd2, err := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.fdNetdev(fd2) != eth {
+		t.Errorf("socket created on wlan0 with eth0 up")
+	}
+	if f.fdNetdev(fd1) != wlan {
+		t.Errorf("open socket moved off wlan0")
+	}
+	if fd1 == fd2 {
+		t.Errorf("sockets of different interfaces share fd %d", fd1)
+	}
+
+	f.Close(fd1)
+	f.Close(fd2)
+	for _, dev := range []*ifaceNetdev{eth, wlan} {
+		if open, bad := dev.open(); open != 0 || bad != 0 {
+			t.Errorf("%d sockets left open, %d bad closes", open, bad)
+		}
+	}
+	if err := f.Close(fd1); err != ErrClosed {
+		t.Errorf("second Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestFailoverBindMovesSocket(t *testing.T) {
+	eth, wlan := newIfaceNetdev("10.0.0.2"), newIfaceNetdev("10.0.1.2")
+	f := withFailover(t, eth, wlan)
+
+	fd, _ := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	_, ethfd, _ := f.lookup(fd)
+	if err := f.SetSockOpt(fd, _SOL_SOCKET, _SO_KEEPALIVE, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Binding to the address of wlan0 recreates the socket there, with
+	// the options set so far
+	laddr := netip.MustParseAddrPort("10.0.1.2:8080")
+	if err := f.Bind(fd, laddr); err != nil {
+		t.Fatal(err)
+	}
+	iface, wlanfd, _ := f.lookup(fd)
+	if iface.dev != wlan {
+		t.Fatalf("bound socket on %s, want wlan0", iface.name)
+	}
+	if !eth.socket(ethfd).closed {
+		t.Errorf("socket left open on eth0")
+	}
+	s := wlan.socket(wlanfd)
+	if s.bound != laddr || s.opts[_SO_KEEPALIVE] != true {
+		t.Errorf("socket on wlan0 bound to %v with options %v, want %v with SO_KEEPALIVE", s.bound, s.opts, laddr)
+	}
+
+	// The interface of a bound socket is final
+	if err := f.SetSockOpt(fd, _SOL_SOCKET, _SO_BINDTODEVICE, "eth0"); err != errSocketBound {
+		t.Errorf("SO_BINDTODEVICE after Bind = %v, want %v", err, errSocketBound)
+	}
+}
+
+func TestFailoverBindToDevice(t *testing.T) {
+	eth, wlan := newIfaceNetdev("10.0.0.2"), newIfaceNetdev("10.0.1.2")
+	f := withFailover(t, eth, wlan)
+
+	fd, _ := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	if err := f.SetSockOpt(fd, _SOL_SOCKET, _SO_BINDTODEVICE, "wlan0"); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := f.GetSockOpt(fd, _SOL_SOCKET, _SO_BINDTODEVICE); name != "wlan0" || err != nil {
+		t.Errorf("GetSockOpt(SO_BINDTODEVICE) = %v, %v, want wlan0", name, err)
+	}
+	if err := f.Connect(fd, "example.com", netip.AddrPort{}); err != nil {
+		t.Fatal(err)
+	}
+	_, wlanfd, _ := f.lookup(fd)
+	if !wlan.socket(wlanfd).connected {
+		t.Errorf("socket not connected on wlan0")
+	}
+	// GetSockOpt is passed on only to netdevs supporting it
+	if _, err := f.GetSockOpt(fd, _SOL_SOCKET, _SO_KEEPALIVE); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("GetSockOpt(SO_KEEPALIVE) = %v, want %v", err, errors.ErrUnsupported)
+	}
+
+	fd2, _ := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	if err := f.SetSockOpt(fd2, _SOL_SOCKET, _SO_BINDTODEVICE, "ppp0"); err != errNoSuchInterface {
+		t.Errorf("SO_BINDTODEVICE to unknown interface = %v, want %v", err, errNoSuchInterface)
+	}
+}
+
+func TestFailoverPlaceRacesClose(t *testing.T) {
+	eth, wlan := newIfaceNetdev("10.0.0.2"), newIfaceNetdev("10.0.1.2")
+	f := withFailover(t, eth, wlan)
+
+	for i := 0; i < 200; i++ {
+		fd, _ := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			f.SetSockOpt(fd, _SOL_SOCKET, _SO_BINDTODEVICE, "wlan0")
+		}()
+		go func() {
+			defer wg.Done()
+			f.Close(fd)
+		}()
+		wg.Wait()
+	}
+	for _, dev := range []*ifaceNetdev{eth, wlan} {
+		if open, bad := dev.open(); open != 0 || bad != 0 {
+			t.Errorf("%d sockets leaked, %d closed twice", open, bad)
+		}
+	}
+}
+
+func TestFailoverPoll(t *testing.T) {
+	eth, wlan := newIfaceNetdev("10.0.0.2"), newIfaceNetdev("10.0.1.2")
+	f := withFailover(t, eth, wlan)
+
+	fd1, _ := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	fd2, _ := f.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	f.SetSockOpt(fd2, _SOL_SOCKET, _SO_BINDTODEVICE, "wlan0")
+	_, wlanfd, _ := f.lookup(fd2)
+
+	fds := []int{fd1, fd2}
+	events := []int{_POLLIN, _POLLIN}
+	revents := make([]int, 2)
+
+	// Nothing ready: Poll waits for the deadline, polling both
+	// interfaces in turn
+	start := time.Now()
+	n, err := f.Poll(fds, events, revents, time.Now().Add(30*time.Millisecond))
+	if n != 0 || err != nil {
+		t.Fatalf("Poll = %d, %v, want 0, nil", n, err)
+	}
+	if d := time.Since(start); d < 25*time.Millisecond {
+		t.Errorf("Poll returned after %v, before the deadline", d)
+	}
+
+	// The socket ready on wlan0 is reported at its index
+	wlan.mu.Lock()
+	wlan.ready[wlanfd] = _POLLIN
+	wlan.mu.Unlock()
+	n, err = f.Poll(fds, events, revents, time.Now().Add(time.Second))
+	if n != 1 || err != nil || revents[0] != 0 || revents[1] != _POLLIN {
+		t.Errorf("Poll = %d, %v, revents %v, want 1, nil, [0 %d]", n, err, revents, _POLLIN)
+	}
+
+	// A closed socket is reported with _POLLERR
+	f.Close(fd1)
+	revents[0], revents[1] = 0, 0
+	n, err = f.Poll(fds, events, revents, time.Now().Add(time.Second))
+	if n != 2 || err != nil || revents[0] != _POLLERR {
+		t.Errorf("Poll = %d, %v, revents %v, want 2, nil, [%d %d]", n, err, revents, _POLLERR, _POLLIN)
+	}
+}
@@ -13,7 +13,6 @@ package http
 
 import (
 	"bufio"
-	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -153,7 +152,7 @@ func (c *Client) send(req *Request, deadline time.Time) (resp *Response, didTime
 			req.AddCookie(cookie)
 		}
 	}
-	resp, didTimeout, err = send(req, deadline)
+	resp, didTimeout, err = send(req, c.transport(), deadline)
 	if err != nil {
 		return nil, didTimeout, err
 	}
@@ -172,11 +171,21 @@ func (c *Client) deadline() time.Time {
 	return time.Time{}
 }
 
+func (c *Client) transport() RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return DefaultTransport
+}
+
 // send issues an HTTP request.
 // Caller should close resp.Body when done reading from it.
-func send(req *Request, deadline time.Time) (resp *Response, didTimeout func() bool, err error) {
+func send(req *Request, rt RoundTripper, deadline time.Time) (resp *Response, didTimeout func() bool, err error) {
 
-	// TINYGO: Removed round tripper
+	if rt == nil {
+		req.closeBody()
+		return nil, alwaysFalse, errors.New("http: no Client.Transport or DefaultTransport")
+	}
 
 	if req.URL == nil {
 		req.closeBody()
@@ -203,7 +212,7 @@ func send(req *Request, deadline time.Time) (resp *Response, didTimeout func() b
 		req.Header.Set("Authorization", "Basic "+basicAuth(username, password))
 	}
 
-	resp, err = roundTrip(req)
+	resp, err = rt.RoundTrip(req)
 	if err != nil {
 
 		// TINYGO: Remove TLS error check
@@ -219,7 +228,7 @@ func send(req *Request, deadline time.Time) (resp *Response, didTimeout func() b
 	return resp, nil, nil
 }
 
-func roundTrip(req *Request) (*Response, error) {
+func (t *Transport) roundTrip(req *Request) (*Response, error) {
 
 	// TINYGO: This is an approximation of Transport.roudTrip()
 
@@ -268,24 +277,25 @@ func roundTrip(req *Request) (*Response, error) {
 	// TINYGO: send the request, read and return the response.
 	// TINYGO: The connection is closed when resp body is closed.
 
-	var conn net.Conn
-	var err error
-
 	host := req.Host
 	missingPort := !strings.Contains(host, ":")
+	if missingPort {
+		host = net.JoinHostPort(host, portMap[scheme])
+	}
 
-	switch scheme {
-	case "http":
-		if missingPort {
-			host = host + ":80"
-		}
-		conn, err = net.Dial("tcp", host)
-	case "https":
-		if missingPort {
-			host = host + ":443"
+	ctx := req.Context()
+
+	var proxyURL *url.URL
+	if t.Proxy != nil {
+		var err error
+		proxyURL, err = t.Proxy(req)
+		if err != nil {
+			req.closeBody()
+			return nil, err
 		}
-		conn, err = tls.Dial("tcp", host, nil)
 	}
+
+	conn, forwardProxy, err := t.dialConn(ctx, scheme, host, proxyURL)
 	if err != nil {
 		req.closeBody()
 		return nil, err
@@ -293,8 +303,17 @@ func roundTrip(req *Request) (*Response, error) {
 
 	// TINYGO: TODO handle timeouts
 
+	// A forwarding proxy gets the absolute URI, and the proxy's
+	// credentials unless the caller set them itself.
+	var extraHeaders Header
+	if forwardProxy && req.Header.Get("Proxy-Authorization") == "" {
+		if pa := proxyAuth(proxyURL); pa != "" {
+			extraHeaders = Header{"Proxy-Authorization": {pa}}
+		}
+	}
+
 	writer := bufio.NewWriter(conn)
-	if err = req.Write(writer); err != nil {
+	if err = req.write(writer, forwardProxy, extraHeaders, nil); err != nil {
 		req.closeBody()
 		return nil, err
 	}
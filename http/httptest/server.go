@@ -1,5 +1,6 @@
 // TINYGO: Removed https support
 // TINYGO: Removed closeIdleTransport interface
+// TINYGO: Fall back to an in-memory net.PipeListener without a netdev
 
 // Copyright 2011 The Go Authors. All rights reserved.
 // Use of this source code is governed by a BSD-style
@@ -62,7 +63,8 @@ func newLocalListener() net.Listener {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		if l, err = net.Listen("tcp6", "[::1]:0"); err != nil {
-			panic(fmt.Sprintf("httptest: failed to listen on a port: %v", err))
+			// TINYGO: No netdev (or no loopback); serve in-memory
+			return net.ListenPipe()
 		}
 	}
 	return l
@@ -122,8 +124,11 @@ func (s *Server) Start() {
 	}
 
 	if s.client == nil {
-		// TINYGO: Removed transport
+		// TINYGO: Removed transport, except to dial an in-memory listener
 		s.client = &http.Client{}
+		if pl, ok := s.Listener.(*net.PipeListener); ok {
+			s.client.Transport = &http.Transport{DialContext: pl.DialContext}
+		}
 	}
 	s.URL = "http://" + s.Listener.Addr().String()
 	s.wrap()
@@ -0,0 +1,99 @@
+// Package netdebug serves via its HTTP server runtime data about the
+// sockets opened by the net package, to help track down leaked sockets
+// on devices in the field.
+//
+// The package is typically only imported for the side effect of
+// registering its HTTP handlers.
+// The handled paths all begin with /debug/net/.
+//
+// To use netdebug, link this package into your program:
+//
+//	import _ "net/http/netdebug"
+//
+// To record where each socket was created, also call
+// net.SetSocketStacks(true) early in your program.
+//
+// The list of open sockets is then available at /debug/net/sockets, and
+// the net package's traffic statistics at /debug/net/stats.
+package netdebug
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	http.HandleFunc("/debug/net/sockets", Sockets)
+	http.HandleFunc("/debug/net/stats", Stats)
+}
+
+// Sockets responds with the list of sockets currently opened by the net
+// package, oldest first, as returned by net.OpenSockets.
+// The package initialization registers it as /debug/net/sockets.
+func Sockets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	socks := net.OpenSockets()
+	now := time.Now()
+
+	fmt.Fprintf(w, "%d open sockets\n\n", len(socks))
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FD\tOWNER\tNET\tLOCAL\tREMOTE\tAGE\tSENT\tRECEIVED")
+	for _, s := range socks {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n",
+			s.FD, s.Owner, s.Network, addrString(s.LocalAddr),
+			addrString(s.RemoteAddr), now.Sub(s.Created).Round(time.Second),
+			s.BytesSent, s.BytesReceived)
+	}
+	tw.Flush()
+
+	for _, s := range socks {
+		if s.Stack == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\nfd %d created at:\n%s", s.FD, s.Stack)
+	}
+}
+
+// Stats responds with the net package's global traffic statistics, as
+// returned by net.ReadStats.
+// The package initialization registers it as /debug/net/stats.
+func Stats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	var s net.Stats
+	net.ReadStats(&s)
+
+	fmt.Fprintf(w, "bytes_sent %d\n", s.BytesSent)
+	fmt.Fprintf(w, "bytes_received %d\n", s.BytesReceived)
+	fmt.Fprintf(w, "packets_sent %d\n", s.PacketsSent)
+	fmt.Fprintf(w, "packets_received %d\n", s.PacketsReceived)
+	fmt.Fprintf(w, "send_errors %d\n", s.SendErrors)
+	fmt.Fprintf(w, "recv_errors %d\n", s.RecvErrors)
+	fmt.Fprintf(w, "sockets_opened %d\n", s.SocketsOpened)
+	fmt.Fprintf(w, "sockets_closed %d\n", s.SocketsClosed)
+	fmt.Fprintf(w, "dials %d\n", s.Dials)
+	fmt.Fprintf(w, "dial_errors %d\n", s.DialErrors)
+	for i, n := range s.DialLatency {
+		le := "+Inf"
+		if i < len(net.DialLatencyBuckets) {
+			le = net.DialLatencyBuckets[i].String()
+		}
+		fmt.Fprintf(w, "dial_latency{le=%q} %d\n", le, n)
+	}
+	fmt.Fprintf(w, "lookups %d\n", s.Lookups)
+	fmt.Fprintf(w, "lookup_errors %d\n", s.LookupErrors)
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return "-"
+	}
+	return a.String()
+}
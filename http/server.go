@@ -7,7 +7,8 @@
 // TINYGO: Removed ALPN protocol support
 // TINYGO: Removed some HTTP/2 support
 // TINYGO: Removed TimeoutHandler
-// TINYGO: Removed ServeTLS and ListenAndServeTLS
+// TINYGO: ServeTLS and ListenAndServeTLS serve HTTP/1.x only, using the
+// TINYGO: netdev's TLS server offload if possible
 
 // Copyright 2009 The Go Authors. All rights reserved.
 // Use of this source code is governed by a BSD-style
@@ -25,13 +26,16 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"math/rand"
 	"net"
+	"net/netlog"
 	"net/textproto"
 	"net/url"
 	urlpkg "net/url"
 	"path"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -644,7 +648,8 @@ func (srv *Server) newConn(rwc net.Conn) *conn {
 		rwc:    rwc,
 	}
 	if debugServerConnections {
-		c.rwc = newLoggingConn("server", c.rwc)
+		// TINYGO: log with netlog.Conn instead of a private loggingConn
+		c.rwc = netlog.NewConn(c.rwc, &netlog.Options{Name: "server", Level: slog.LevelInfo})
 	}
 	return c
 }
@@ -1880,6 +1885,56 @@ func isCommonNetReadError(err error) bool {
 	return false
 }
 
+// unwrapTLSConn returns the *tls.Conn or *net.TLSConn rwc wraps, looking
+// through conns with an Unwrap method such as those of Server.WrapConn.
+// Otherwise, it returns rwc.
+func unwrapTLSConn(rwc net.Conn) net.Conn {
+	for {
+		switch rwc.(type) {
+		case *tls.Conn, *net.TLSConn:
+			return rwc
+		}
+		u, ok := rwc.(interface{ Unwrap() net.Conn })
+		if !ok {
+			return rwc
+		}
+		rwc = u.Unwrap()
+	}
+}
+
+// tlsHandshake runs the TLS handshake of c with handshake, within the
+// server's TLS handshake timeout.  It reports whether the handshake
+// succeeded.
+func (c *conn) tlsHandshake(ctx context.Context, handshake func(context.Context) error) bool {
+	tlsTO := c.server.tlsHandshakeTimeout()
+	if tlsTO > 0 {
+		dl := time.Now().Add(tlsTO)
+		c.rwc.SetReadDeadline(dl)
+		c.rwc.SetWriteDeadline(dl)
+	}
+	if err := handshake(ctx); err != nil {
+		// If the handshake failed due to the client not speaking
+		// TLS, assume they're speaking plaintext HTTP and write a
+		// 400 response on the TLS conn's underlying net.Conn.
+		var reason string
+		if re, ok := err.(tls.RecordHeaderError); ok && re.Conn != nil && tlsRecordHeaderLooksLikeHTTP(re.RecordHeader) {
+			io.WriteString(re.Conn, "HTTP/1.0 400 Bad Request\r\n\r\nClient sent an HTTP request to an HTTPS server.\n")
+			re.Conn.Close()
+			reason = "client sent an HTTP request to an HTTPS server"
+		} else {
+			reason = err.Error()
+		}
+		c.server.logf("http: TLS handshake error from %s: %v", c.rwc.RemoteAddr(), reason)
+		return false
+	}
+	// Restore Conn-level deadlines.
+	if tlsTO > 0 {
+		c.rwc.SetReadDeadline(time.Time{})
+		c.rwc.SetWriteDeadline(time.Time{})
+	}
+	return true
+}
+
 // Serve a new connection.
 func (c *conn) serve(ctx context.Context) {
 	if ra := c.rwc.RemoteAddr(); ra != nil {
@@ -1907,7 +1962,22 @@ func (c *conn) serve(ctx context.Context) {
 		}
 	}()
 
-	// TINYGO: Removed TLS conn check
+	// TINYGO: Handshake crypto/tls and netdev TLS offload conns; ALPN
+	// TINYGO: protocols (TLSNextProto) are not supported.  Look through
+	// TINYGO: the conns Server.WrapConn wrapped around them.
+	tlsRwc := unwrapTLSConn(c.rwc)
+	if tlsConn, ok := tlsRwc.(*tls.Conn); ok {
+		if !c.tlsHandshake(ctx, tlsConn.HandshakeContext) {
+			return
+		}
+		c.tlsState = new(tls.ConnectionState)
+		*c.tlsState = tlsConn.ConnectionState()
+	} else if tlsConn, ok := tlsRwc.(*net.TLSConn); ok {
+		if !c.tlsHandshake(ctx, tlsConn.HandshakeContext) {
+			return
+		}
+		c.tlsState = offloadTLSState(tlsConn.ConnectionState())
+	}
 
 	// HTTP/1.x from here on.
 
@@ -2692,6 +2762,23 @@ func Serve(l net.Listener, handler Handler) error {
 	return srv.Serve(l)
 }
 
+// ServeTLS accepts incoming HTTPS connections on the listener l,
+// creating a new service goroutine for each. The service goroutines
+// read requests and then call handler to reply to them.
+//
+// The handler is typically nil, in which case [DefaultServeMux] is used.
+//
+// Additionally, files containing a certificate and matching private key
+// for the server must be provided. If the certificate is signed by a
+// certificate authority, the certFile should be the concatenation
+// of the server's certificate, any intermediates, and the CA's certificate.
+//
+// ServeTLS always returns a non-nil error.
+func ServeTLS(l net.Listener, handler Handler, certFile, keyFile string) error {
+	srv := &Server{Handler: handler}
+	return srv.ServeTLS(l, certFile, keyFile)
+}
+
 // A Server defines parameters for running an HTTP server.
 // The zero value for Server is a valid configuration.
 type Server struct {
@@ -2779,6 +2866,20 @@ type Server struct {
 	// value.
 	ConnContext func(ctx context.Context, c net.Conn) context.Context
 
+	// TINYGO: WrapConn optionally specifies a function that wraps each
+	// accepted connection before it is served, e.g. to log or to shape
+	// its traffic.  The returned net.Conn is what handlers get when
+	// hijacking the connection.  If WrapConn is nil or returns nil, the
+	// connection is served as accepted.  To serve TLS, the returned
+	// net.Conn must have an Unwrap() net.Conn method returning the
+	// connection it wraps.
+	WrapConn func(net.Conn) net.Conn
+
+	// TINYGO: OnLinkChange selects what the server does when the netdev
+	// reports a link or address change; see net.OnLinkChange.  If zero,
+	// the server does nothing.
+	OnLinkChange LinkChangeAction
+
 	inShutdown atomicBool // true when server is in shutdown
 
 	disableKeepAlives int32     // accessed atomically.
@@ -2793,6 +2894,105 @@ type Server struct {
 	listenerGroup sync.WaitGroup
 }
 
+// TINYGO: A LinkChangeAction is a set of actions a [Server] takes when the
+// netdev reports a link or address change.
+type LinkChangeAction int
+
+const (
+	// LinkCloseConns closes the server's connections when the link goes
+	// down or the address changes, failing in-flight requests quickly
+	// instead of leaving them to time out on dead sockets.  Hijacked
+	// connections are not closed.
+	LinkCloseConns LinkChangeAction = 1 << iota
+
+	// LinkRelisten closes and recreates the listener when the link comes
+	// back up or the address changes, so a listener bound to a stale
+	// address is replaced.  It only applies to listeners created by
+	// ListenAndServe and ListenAndServeTLS.
+	LinkRelisten
+)
+
+// closeConnsOnLinkChange closes the active connections each time the link
+// goes down or the address changes, until stop is called.
+func (s *Server) closeConnsOnLinkChange() (stop func()) {
+	return net.OnLinkChange(func(e net.LinkEvent) {
+		if e.Up && !e.AddrChanged() {
+			return
+		}
+		// f must not block, so close the conns, which may take a
+		// netdev round trip each, on a goroutine
+		s.mu.Lock()
+		conns := make([]net.Conn, 0, len(s.activeConn))
+		for c := range s.activeConn {
+			conns = append(conns, c.rwc)
+		}
+		s.mu.Unlock()
+		go func() {
+			for _, rwc := range conns {
+				rwc.Close()
+			}
+		}()
+	})
+}
+
+// serveListen calls Serve with the listener returned by listen.  With
+// LinkRelisten, the listener is closed and listen is called again each
+// time the link comes back up or the address changes.  If listen fails
+// then, it is retried with backoff until the server shuts down.
+func (srv *Server) serveListen(listen func() (net.Listener, error)) error {
+	ln, err := listen()
+	if err != nil {
+		return err
+	}
+	for {
+		if srv.OnLinkChange&LinkRelisten == 0 {
+			return srv.Serve(ln)
+		}
+
+		var relisten atomic.Bool
+		cur := ln
+		stop := net.OnLinkChange(func(e net.LinkEvent) {
+			if e.Up && (!e.WasUp || e.AddrChanged()) && !relisten.Swap(true) {
+				cur.Close()
+			}
+		})
+		err = srv.Serve(cur)
+		stop()
+		if !relisten.Load() || srv.shuttingDown() {
+			return err
+		}
+		srv.logf("http: link changed; recreating listener")
+		if ln, err = srv.relisten(listen); err != nil {
+			return err
+		}
+	}
+}
+
+// relisten calls listen until it succeeds, backing off between attempts
+// like Serve does on Accept errors, or until the server shuts down.
+func (srv *Server) relisten(listen func() (net.Listener, error)) (net.Listener, error) {
+	var tempDelay time.Duration // how long to sleep on listen failure
+	for {
+		if srv.shuttingDown() {
+			return nil, ErrServerClosed
+		}
+		ln, err := listen()
+		if err == nil {
+			return ln, nil
+		}
+		if tempDelay == 0 {
+			tempDelay = 5 * time.Millisecond
+		} else {
+			tempDelay *= 2
+		}
+		if max := 1 * time.Second; tempDelay > max {
+			tempDelay = max
+		}
+		srv.logf("http: listen error: %v; retrying in %v", err, tempDelay)
+		time.Sleep(tempDelay)
+	}
+}
+
 // Close immediately closes all active net.Listeners and any
 // connections in state StateNew, StateActive, or StateIdle. For a
 // graceful shutdown, use Shutdown.
@@ -3047,11 +3247,9 @@ func (srv *Server) ListenAndServe() error {
 	if addr == "" {
 		addr = ":http"
 	}
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		return err
-	}
-	return srv.Serve(ln)
+	return srv.serveListen(func() (net.Listener, error) {
+		return net.Listen("tcp", addr)
+	})
 }
 
 var testHookServerServe func(*Server, net.Listener) // used if non-nil
@@ -3092,6 +3290,10 @@ func (srv *Server) Serve(l net.Listener) error {
 		}
 	}
 
+	if srv.OnLinkChange&LinkCloseConns != 0 {
+		defer srv.closeConnsOnLinkChange()()
+	}
+
 	var tempDelay time.Duration // how long to sleep on accept failure
 
 	ctx := context.WithValue(baseCtx, ServerContextKey, srv)
@@ -3116,6 +3318,11 @@ func (srv *Server) Serve(l net.Listener) error {
 			}
 			return err
 		}
+		if srv.WrapConn != nil {
+			if wrapped := srv.WrapConn(rw); wrapped != nil {
+				rw = wrapped
+			}
+		}
 		connCtx := ctx
 		if cc := srv.ConnContext; cc != nil {
 			connCtx = cc(connCtx, rw)
@@ -3243,6 +3450,109 @@ func ListenAndServe(addr string, handler Handler) error {
 	return server.ListenAndServe()
 }
 
+// ListenAndServeTLS acts identically to [ListenAndServe], except that it
+// expects HTTPS connections. Additionally, files containing a certificate and
+// matching private key for the server must be provided. If the certificate
+// is signed by a certificate authority, the certFile should be the concatenation
+// of the server's certificate, any intermediates, and the CA's certificate.
+func ListenAndServeTLS(addr, certFile, keyFile string, handler Handler) error {
+	server := &Server{Addr: addr, Handler: handler}
+	return server.ListenAndServeTLS(certFile, keyFile)
+}
+
+func (srv *Server) setupTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	config := cloneTLSConfig(srv.TLSConfig)
+	if !slices.Contains(config.NextProtos, "http/1.1") {
+		config.NextProtos = append(config.NextProtos, "http/1.1")
+	}
+
+	configHasCert := len(config.Certificates) > 0 || config.GetCertificate != nil || config.GetConfigForClient != nil
+	if !configHasCert || certFile != "" || keyFile != "" {
+		var err error
+		config.Certificates = make([]tls.Certificate, 1)
+		config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// ServeTLS accepts incoming connections on the Listener l, creating a
+// new service goroutine for each. The service goroutines perform TLS
+// setup and then read requests, calling srv.Handler to reply to them.
+//
+// Files containing a certificate and matching private key for the
+// server must be provided if neither the [Server]'s
+// TLSConfig.Certificates, TLSConfig.GetCertificate nor
+// config.GetConfigForClient are populated.
+// If the certificate is signed by a certificate authority, the
+// certFile should be the concatenation of the server's certificate,
+// any intermediates, and the CA's certificate.
+//
+// ServeTLS always returns a non-nil error. After [Server.Shutdown] or [Server.Close], the
+// returned error is [ErrServerClosed].
+//
+// TINYGO: TLS runs in software with crypto/tls; HTTP/2 is not supported.
+func (srv *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	config, err := srv.setupTLSConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	tlsListener := tls.NewListener(l, config)
+	return srv.Serve(tlsListener)
+}
+
+// ListenAndServeTLS listens on the TCP network address srv.Addr and
+// then calls [ServeTLS] to handle requests on incoming TLS connections.
+// Accepted connections are configured to enable TCP keep-alives.
+//
+// Filenames containing a certificate and matching private key for the
+// server must be provided if neither the [Server]'s TLSConfig.Certificates
+// nor TLSConfig.GetCertificate are populated. If the certificate is
+// signed by a certificate authority, the certFile should be the
+// concatenation of the server's certificate, any intermediates, and
+// the CA's certificate.
+//
+// If srv.Addr is blank, ":https" is used.
+//
+// ListenAndServeTLS always returns a non-nil error. After [Server.Shutdown] or
+// [Server.Close], the returned error is [ErrServerClosed].
+//
+// TINYGO: The netdev's TLS server offload is used if the netdev has one
+// and the TLS config can be expressed as a net.TLSConfig.  Otherwise TLS
+// runs in software with crypto/tls.
+func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+
+	config, err := srv.setupTLSConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	return srv.serveListen(func() (net.Listener, error) {
+		if offloadConfig, ok := offloadTLSConfig(config); ok {
+			ln, err := net.ListenTLS("tcp", addr, offloadConfig)
+			if !offloadUnsupported(err) {
+				return ln, err
+			}
+		}
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return tls.NewListener(ln, config), nil
+	})
+}
+
 // onceCloseListener wraps a net.Listener, protecting it from
 // multiple Close calls.
 type onceCloseListener struct {
@@ -3274,48 +3584,6 @@ func (globalOptionsHandler) ServeHTTP(w ResponseWriter, r *Request) {
 	}
 }
 
-// loggingConn is used for debugging.
-type loggingConn struct {
-	name string
-	net.Conn
-}
-
-var (
-	uniqNameMu   sync.Mutex
-	uniqNameNext = make(map[string]int)
-)
-
-func newLoggingConn(baseName string, c net.Conn) net.Conn {
-	uniqNameMu.Lock()
-	defer uniqNameMu.Unlock()
-	uniqNameNext[baseName]++
-	return &loggingConn{
-		name: fmt.Sprintf("%s-%d", baseName, uniqNameNext[baseName]),
-		Conn: c,
-	}
-}
-
-func (c *loggingConn) Write(p []byte) (n int, err error) {
-	log.Printf("%s.Write(%d) = ....", c.name, len(p))
-	n, err = c.Conn.Write(p)
-	log.Printf("%s.Write(%d) = %d, %v", c.name, len(p), n, err)
-	return
-}
-
-func (c *loggingConn) Read(p []byte) (n int, err error) {
-	log.Printf("%s.Read(%d) = ....", c.name, len(p))
-	n, err = c.Conn.Read(p)
-	log.Printf("%s.Read(%d) = %d, %v", c.name, len(p), n, err)
-	return
-}
-
-func (c *loggingConn) Close() (err error) {
-	log.Printf("%s.Close() = ...", c.name)
-	err = c.Conn.Close()
-	log.Printf("%s.Close() = %v", c.name, err)
-	return
-}
-
 // checkConnErrorWriter writes to c.rwc and records any write errors to c.werr.
 // It only contains one field (and a pointer field at that), so it
 // fits in an interface value without an extra allocation.
@@ -0,0 +1,153 @@
+// TINYGO: crypto/tls glue for the net package's TLS, which can't import
+// TINYGO: crypto/tls: the software TLS client net.DialTLS falls back to when
+// TINYGO: the netdev has no TLS offload, and the conversions for serving
+// TINYGO: HTTPS with the netdev's TLS server offload.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+	_ "unsafe"
+)
+
+//go:linkname useTLSClient net.useTLSClient
+func useTLSClient(client func(ctx context.Context, conn net.Conn, config *net.TLSConfig) (softTLSState, error))
+
+// softTLSState mirrors the net package's tlsClientConn interface
+type softTLSState interface {
+	net.Conn
+	ConnectionState() net.TLSConnectionState
+}
+
+func init() {
+	useTLSClient(softTLSClient)
+}
+
+// softTLSConn is a crypto/tls client connection reporting its state as a
+// net.TLSConnectionState
+type softTLSConn struct {
+	*tls.Conn
+}
+
+func softTLSClient(ctx context.Context, conn net.Conn, config *net.TLSConfig) (softTLSState, error) {
+	cfg, err := softTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	c := tls.Client(conn, cfg)
+	if err := c.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return softTLSConn{c}, nil
+}
+
+// softTLSConfig converts a net.TLSConfig to a tls.Config
+func softTLSConfig(config *net.TLSConfig) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         config.ServerName,
+		MinVersion:         config.MinVersion,
+		MaxVersion:         config.MaxVersion,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		NextProtos:         config.NextProtos,
+	}
+	if config.RootCAs != nil {
+		cfg.RootCAs = x509.NewCertPool()
+		for _, der := range config.RootCAs {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, err
+			}
+			cfg.RootCAs.AddCert(cert)
+		}
+	}
+	if config.Certificate != nil {
+		key, err := x509.ParsePKCS8PrivateKey(config.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{{
+			Certificate: config.Certificate,
+			PrivateKey:  key,
+		}}
+	}
+	return cfg, nil
+}
+
+func (c softTLSConn) ConnectionState() net.TLSConnectionState {
+	cs := c.Conn.ConnectionState()
+	state := net.TLSConnectionState{
+		Version:            cs.Version,
+		HandshakeComplete:  cs.HandshakeComplete,
+		CipherSuite:        cs.CipherSuite,
+		NegotiatedProtocol: cs.NegotiatedProtocol,
+		ServerName:         cs.ServerName,
+	}
+	for _, cert := range cs.PeerCertificates {
+		state.PeerCertificates = append(state.PeerCertificates, cert.Raw)
+	}
+	return state
+}
+
+// offloadUnsupported reports whether err, from net.ListenTLS, means the
+// netdev has no TLS server offload, so HTTPS is served with crypto/tls.
+func offloadUnsupported(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported) || errors.Is(err, syscall.EPROTONOSUPPORT)
+}
+
+// offloadTLSConfig converts the server config to a net.TLSConfig for the
+// netdev's TLS server offload.  It reports false if config uses settings a
+// net.TLSConfig can't express, such as callbacks, a CertPool or a cipher
+// suite policy, as the netdev would silently apply its own defaults
+// instead.
+func offloadTLSConfig(config *tls.Config) (*net.TLSConfig, bool) {
+	if len(config.Certificates) != 1 || config.GetCertificate != nil ||
+		config.GetConfigForClient != nil || config.VerifyPeerCertificate != nil ||
+		config.VerifyConnection != nil || config.ClientCAs != nil {
+		return nil, false
+	}
+	if config.CipherSuites != nil || config.CurvePreferences != nil ||
+		config.SessionTicketsDisabled || config.SessionTicketKey != [32]byte{} ||
+		config.WrapSession != nil || config.UnwrapSession != nil ||
+		config.Renegotiation != tls.RenegotiateNever || config.KeyLogWriter != nil ||
+		config.Rand != nil || config.Time != nil || config.DynamicRecordSizingDisabled {
+		return nil, false
+	}
+	cert := config.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return nil, false
+	}
+	return &net.TLSConfig{
+		Certificate:     cert.Certificate,
+		PrivateKey:      key,
+		MinVersion:      config.MinVersion,
+		MaxVersion:      config.MaxVersion,
+		NextProtos:      config.NextProtos,
+		ClientAuth:      int(config.ClientAuth),
+		DisableFallback: true,
+	}, true
+}
+
+// offloadTLSState converts the state of a netdev TLS offload conn for
+// Request.TLS.  Peer certificates the netdev reports but crypto/x509 can't
+// parse are left out.
+func offloadTLSState(state net.TLSConnectionState) *tls.ConnectionState {
+	cs := &tls.ConnectionState{
+		Version:            state.Version,
+		HandshakeComplete:  state.HandshakeComplete,
+		CipherSuite:        state.CipherSuite,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+	}
+	for _, der := range state.PeerCertificates {
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			cs.PeerCertificates = append(cs.PeerCertificates, cert)
+		}
+	}
+	return cs
+}
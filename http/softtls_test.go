@@ -0,0 +1,49 @@
+// TINYGO: netdev TLS server offload config tests
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"testing"
+)
+
+func TestOffloadTLSConfig(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{{0x30}}, PrivateKey: key}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	nc, ok := offloadTLSConfig(config)
+	if !ok {
+		t.Fatal("offloadTLSConfig refused a plain config")
+	}
+	if nc.MinVersion != tls.VersionTLS12 || len(nc.NextProtos) != 2 || len(nc.PrivateKey) == 0 {
+		t.Errorf("offloadTLSConfig = %+v", nc)
+	}
+
+	// Settings the netdev can't be told about keep HTTPS in software
+	for name, set := range map[string]func(*tls.Config){
+		"CipherSuites":           func(c *tls.Config) { c.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256} },
+		"CurvePreferences":       func(c *tls.Config) { c.CurvePreferences = []tls.CurveID{tls.X25519} },
+		"SessionTicketsDisabled": func(c *tls.Config) { c.SessionTicketsDisabled = true },
+		"GetCertificate": func(c *tls.Config) {
+			c.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &cert, nil }
+		},
+		"two certificates": func(c *tls.Config) { c.Certificates = append(c.Certificates, cert) },
+	} {
+		c := config.Clone()
+		set(c)
+		if _, ok := offloadTLSConfig(c); ok {
+			t.Errorf("offloadTLSConfig accepted a config with %s", name)
+		}
+	}
+}
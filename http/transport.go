@@ -12,8 +12,21 @@
 package http
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
+	"net"
+	"net/socks"
+	"net/url"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
 )
 
 type readTrackingBody struct {
@@ -22,6 +35,314 @@ type readTrackingBody struct {
 	didClose atomic.Bool
 }
 
-type Transport struct{}
+// Transport is an implementation of [RoundTripper] that supports HTTP,
+// HTTPS, and HTTP proxies (for either HTTP or HTTPS with CONNECT).
+//
+// TINYGO: Connections are not cached; each request dials a new connection,
+// which is closed once the response body is read.
+type Transport struct {
+	// Proxy specifies a function to return a proxy for a given
+	// Request. If the function returns a non-nil error, the
+	// request is aborted with the provided error.
+	//
+	// The proxy type is determined by the URL scheme. "http",
+	// "https", "socks5", and "socks5h" are supported. If the scheme is empty,
+	// "http" is assumed.
+	// "socks5" is treated the same as "socks5h".
+	//
+	// If the proxy URL contains a userinfo subcomponent,
+	// the proxy request will pass the username and password
+	// in a Proxy-Authorization header.
+	//
+	// If Proxy is nil or returns a nil *URL, no proxy is used.
+	//
+	// TINYGO: HTTPS requests through a proxy run crypto/tls over the
+	// tunnel; an "https" proxy itself is reached like any HTTPS server,
+	// so its TLS can be offloaded to the netdev.
+	Proxy func(*Request) (*url.URL, error)
+
+	// ProxyConnectHeader optionally specifies headers to send to
+	// proxies during CONNECT requests.
+	ProxyConnectHeader Header
+
+	// DialContext specifies the dial function for creating unencrypted TCP connections.
+	// If DialContext is nil, then the transport dials using package net.
+	//
+	// DialContext runs concurrently with calls to RoundTrip.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// DialTLSContext specifies an optional dial function for creating
+	// TLS connections for non-proxied HTTPS requests.
+	//
+	// If DialTLSContext is nil, tls.Dial is used.
+	//
+	// TINYGO: Unless DialContext is set; then, like upstream, TLS runs
+	// with crypto/tls over the connection DialContext returns, so HTTPS
+	// requests go through custom dialers such as SOCKS5 proxies.
+	//
+	// If DialTLSContext is set, the DialContext hook is not used for HTTPS
+	// requests. The returned net.Conn is assumed to already be
+	// past the TLS handshake.
+	DialTLSContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
 
+// DefaultTransport is the default implementation of [Transport] and is
+// used by [DefaultClient]. It establishes network connections as needed.
 var DefaultTransport RoundTripper = &Transport{}
+
+// ProxyFromEnvironment returns the URL of the proxy to use for a
+// given request, as indicated by the environment variables
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY (or the lowercase versions
+// thereof). Requests use the proxy from the environment variable
+// matching their scheme, unless excluded by NO_PROXY.
+//
+// The environment values may be either a complete URL or a
+// "host[:port]", in which case the "http" scheme is assumed.
+// An error is returned if the value is a different form.
+//
+// A nil URL and nil error are returned if no proxy is defined in the
+// environment, or a proxy should not be used for the given request,
+// as defined by NO_PROXY.
+//
+// As a special case, if req.URL.Host is "localhost" (with or without
+// a port number), then a nil URL and nil error will be returned.
+func ProxyFromEnvironment(req *Request) (*url.URL, error) {
+	return envProxyFunc()(req.URL)
+}
+
+// ProxyURL returns a proxy function (for use in a [Transport])
+// that always returns the same URL.
+func ProxyURL(fixedURL *url.URL) func(*Request) (*url.URL, error) {
+	return func(*Request) (*url.URL, error) {
+		return fixedURL, nil
+	}
+}
+
+var (
+	// proxyConfigOnce guards proxyConfig
+	envProxyOnce      sync.Once
+	envProxyFuncValue func(*url.URL) (*url.URL, error)
+)
+
+// envProxyFunc returns a function that reads the
+// environment variable to determine the proxy address.
+func envProxyFunc() func(*url.URL) (*url.URL, error) {
+	envProxyOnce.Do(func() {
+		envProxyFuncValue = httpproxy.FromEnvironment().ProxyFunc()
+	})
+	return envProxyFuncValue
+}
+
+var zeroDialer net.Dialer
+
+func (t *Transport) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if t.DialContext != nil {
+		c, err := t.DialContext(ctx, network, addr)
+		if c == nil && err == nil {
+			err = errors.New("net/http: Transport.DialContext hook returned (nil, nil)")
+		}
+		return c, err
+	}
+	return zeroDialer.DialContext(ctx, network, addr)
+}
+
+func (t *Transport) dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	if t.DialTLSContext != nil {
+		c, err := t.DialTLSContext(ctx, network, addr)
+		if c == nil && err == nil {
+			err = errors.New("net/http: Transport.DialTLSContext hook returned (nil, nil)")
+		}
+		return c, err
+	}
+	return t.dialTLSNoHook(ctx, network, addr)
+}
+
+// dialTLSNoHook is dialTLS without the DialTLSContext hook, which is only
+// for non-proxied requests.  HTTPS proxies are dialed with it.
+func (t *Transport) dialTLSNoHook(ctx context.Context, network, addr string) (net.Conn, error) {
+	if t.DialContext != nil {
+		conn, err := t.dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return addTLS(ctx, conn, addr)
+	}
+	return tls.Dial(network, addr, nil)
+}
+
+// addTLS runs a crypto/tls client handshake for addr over conn, closing
+// conn if it fails.
+func addTLS(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+var portMap = map[string]string{
+	"http":    "80",
+	"https":   "443",
+	"socks5":  "1080",
+	"socks5h": "1080",
+}
+
+// canonicalAddr returns url.Host but always with a ":port" suffix.
+func canonicalAddr(url *url.URL) string {
+	port := url.Port()
+	if port == "" {
+		port = portMap[url.Scheme]
+	}
+	return net.JoinHostPort(url.Hostname(), port)
+}
+
+// proxyAuth returns the Proxy-Authorization header value for the
+// userinfo of proxyURL, or "" if it has none.
+func proxyAuth(proxyURL *url.URL) string {
+	if u := proxyURL.User; u != nil {
+		username := u.Username()
+		password, _ := u.Password()
+		return "Basic " + basicAuth(username, password)
+	}
+	return ""
+}
+
+// dialerFunc adapts a dial function to socks.ContextDialer.
+type dialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// dialConn dials addr for a request with the given scheme, through
+// proxyURL if it is non-nil.  It reports whether the returned connection
+// is to an HTTP proxy forwarding the request, which must then be written
+// with an absolute URI.
+func (t *Transport) dialConn(ctx context.Context, scheme, addr string, proxyURL *url.URL) (conn net.Conn, forwardProxy bool, err error) {
+	if proxyURL == nil {
+		if scheme == "https" {
+			conn, err = t.dialTLS(ctx, "tcp", addr)
+		} else {
+			conn, err = t.dial(ctx, "tcp", addr)
+		}
+		return conn, false, err
+	}
+
+	proxyScheme := proxyURL.Scheme
+	if proxyScheme == "" {
+		proxyScheme = "http"
+	}
+	switch proxyScheme {
+	case "socks5", "socks5h":
+		d := socks.NewDialer(canonicalAddr(proxyURL))
+		d.Forward = dialerFunc(t.dial)
+		if u := proxyURL.User; u != nil {
+			d.Auth = &socks.Auth{Username: u.Username()}
+			d.Auth.Password, _ = u.Password()
+		}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, false, err
+		}
+		if scheme == "https" {
+			conn, err = addTLS(ctx, conn, addr)
+		}
+		return conn, false, err
+	case "http":
+		conn, err = t.dial(ctx, "tcp", canonicalAddr(proxyURL))
+	case "https":
+		conn, err = t.dialTLSNoHook(ctx, "tcp", canonicalAddr(proxyURL))
+	default:
+		return nil, false, fmt.Errorf("net/http: unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if scheme == "http" {
+		return conn, true, nil
+	}
+	if err := t.connect(ctx, conn, addr, proxyURL); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	conn, err = addTLS(ctx, conn, addr)
+	return conn, false, err
+}
+
+// connect asks the HTTP proxy at the other end of conn to open a tunnel
+// to addr.
+func (t *Transport) connect(ctx context.Context, conn net.Conn, addr string, proxyURL *url.URL) error {
+	hdr := t.ProxyConnectHeader
+	if hdr == nil {
+		hdr = make(Header)
+	}
+	if pa := proxyAuth(proxyURL); pa != "" {
+		hdr = hdr.Clone()
+		hdr.Set("Proxy-Authorization", pa)
+	}
+	connectReq := &Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: hdr,
+	}
+
+	// Set a (long) timeout here to make sure we don't block forever
+	// and leak a goroutine if the connection stops replying after
+	// the TCP connect.
+	connectCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	didReadResponse := make(chan struct{}) // closed after CONNECT write+read is done or fails
+	var (
+		resp *Response
+		err  error // write or read error
+	)
+	// Write the CONNECT request & read the response.
+	go func() {
+		defer close(didReadResponse)
+		err = connectReq.Write(conn)
+		if err != nil {
+			return
+		}
+		// Okay to use and discard buffered reader here, because
+		// TLS server will not speak until spoken to.
+		br := bufio.NewReader(conn)
+		resp, err = ReadResponse(br, connectReq)
+	}()
+	select {
+	case <-connectCtx.Done():
+		conn.Close()
+		<-didReadResponse
+		return connectCtx.Err()
+	case <-didReadResponse:
+		// resp or err now set
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		_, text, ok := strings.Cut(resp.Status, " ")
+		if !ok {
+			return errors.New("unknown status code")
+		}
+		return errors.New(text)
+	}
+	return nil
+}
+
+// cloneTLSConfig returns a shallow clone of cfg, or a new zero tls.Config if
+// cfg is nil. This is safe to call even if cfg is in active use by a TLS
+// client or server.
+func cloneTLSConfig(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		return &tls.Config{}
+	}
+	return cfg.Clone()
+}
@@ -0,0 +1,119 @@
+// Network interfaces of the netdev
+
+package net
+
+import (
+	"net/netip"
+)
+
+// interfacer is an optional netdever extension for devices describing their
+// network interfaces.  Interfaces returns the interfaces, numbered by Index
+// from 1.  InterfaceAddrs returns the unicast addresses, with their prefix
+// length, and the joined multicast group addresses of the interface with
+// the given index.
+//
+// Without interfacer, the netdev is described as one interface named
+// netdevInterfaceName, whose address is Addr with a full-length prefix.
+type interfacer interface {
+	Interfaces() ([]Interface, error)
+	InterfaceAddrs(index int) (unicast []netip.Prefix, multicast []netip.Addr, err error)
+}
+
+// netdevInterfaceName names the interface of a netdev without interfacer
+const netdevInterfaceName = "netdev0"
+
+// netdevMTU is the MTU of a netdev without interfacer, that of Ethernet
+// and Wi-Fi
+const netdevMTU = 1500
+
+// devInterfaces returns the interfaces of dev.
+func devInterfaces(dev netdever) ([]Interface, error) {
+	if ifr, ok := dev.(interfacer); ok {
+		return ifr.Interfaces()
+	}
+	if _, err := dev.Addr(); err == ErrNetdevNotSet {
+		return nil, err
+	}
+	ifi := Interface{Index: 1, MTU: netdevMTU, Name: netdevInterfaceName, Flags: FlagUp}
+	if up, _ := LinkState(); up {
+		ifi.Flags |= FlagRunning
+	}
+	return []Interface{ifi}, nil
+}
+
+// devInterfaceAddrs returns the addresses of the interface of dev with
+// index.
+func devInterfaceAddrs(dev netdever, index int) ([]netip.Prefix, []netip.Addr, error) {
+	if ifr, ok := dev.(interfacer); ok {
+		return ifr.InterfaceAddrs(index)
+	}
+	if index != 1 {
+		return nil, nil, errNoSuchInterface
+	}
+	ip, err := dev.Addr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ip.IsValid() {
+		return nil, nil, nil
+	}
+	return []netip.Prefix{netip.PrefixFrom(ip, ip.BitLen())}, nil, nil
+}
+
+// If the ifindex is zero, interfaceTable returns mappings of all
+// network interfaces. Otherwise it returns a mapping of a specific
+// interface.
+func interfaceTable(ifindex int) ([]Interface, error) {
+	ift, err := devInterfaces(netdev)
+	if err != nil || ifindex == 0 {
+		return ift, err
+	}
+	for _, ifi := range ift {
+		if ifi.Index == ifindex {
+			return []Interface{ifi}, nil
+		}
+	}
+	return nil, nil
+}
+
+// If the ifi is nil, interfaceAddrTable returns addresses for all
+// network interfaces. Otherwise it returns addresses for a specific
+// interface.
+func interfaceAddrTable(ifi *Interface) ([]Addr, error) {
+	var ift []Interface
+	if ifi == nil {
+		var err error
+		ift, err = interfaceTable(0)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ift = []Interface{*ifi}
+	}
+	var ifat []Addr
+	for _, ifi := range ift {
+		unicast, _, err := devInterfaceAddrs(netdev, ifi.Index)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range unicast {
+			ip := p.Addr().Unmap()
+			ifat = append(ifat, &IPNet{IP: IP(ip.AsSlice()), Mask: CIDRMask(p.Bits(), ip.BitLen())})
+		}
+	}
+	return ifat, nil
+}
+
+// interfaceMulticastAddrTable returns addresses for a specific
+// interface.
+func interfaceMulticastAddrTable(ifi *Interface) ([]Addr, error) {
+	_, multicast, err := devInterfaceAddrs(netdev, ifi.Index)
+	if err != nil {
+		return nil, err
+	}
+	var ifmat []Addr
+	for _, ip := range multicast {
+		ifmat = append(ifmat, &IPAddr{IP: IP(ip.Unmap().AsSlice())})
+	}
+	return ifmat, nil
+}
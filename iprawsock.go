@@ -7,9 +7,13 @@
 package net
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/netip"
 	"syscall"
+	"time"
 )
 
 // BUG(mikio): On every POSIX platform, reads from the "ip4" network
@@ -88,19 +92,147 @@ func (a *IPAddr) opAddr() Addr {
 // See func [Dial] for a description of the network and address
 // parameters.
 func ResolveIPAddr(network, address string) (*IPAddr, error) {
-	return nil, errors.New("ResolveIPAddr not implemented")
+	return resolveIPAddr(context.Background(), nil, network, address)
+}
+
+func resolveIPAddr(ctx context.Context, trace *SocketTrace, network, address string) (*IPAddr, error) {
+	if network == "" { // a hint wildcard for Go 1.0 undocumented behavior
+		network = "ip"
+	}
+	afnet, _, err := parseNetwork(ctx, network, false)
+	if err != nil {
+		return nil, err
+	}
+	switch afnet {
+	case "ip", "ip4":
+	default:
+		return nil, UnknownNetworkError(network)
+	}
+
+	// TINYGO: Use netdev resolver
+
+	if address == "" {
+		return &IPAddr{}, nil
+	}
+	ip, err := lookupHost(trace, address)
+	if err != nil {
+		return nil, fmt.Errorf("Lookup of host name '%s' failed: %s", address, err)
+	}
+	return ipAddrFromAddr(ip), nil
 }
 
 // IPConn is the implementation of the [Conn] and [PacketConn] interfaces
 // for IP network connections.
 type IPConn struct {
-	conn
+	fd            int
+	net           string
+	laddr         *IPAddr
+	raddr         *IPAddr
+	readDeadline  time.Time
+	writeDeadline time.Time
+	sock          *socket
 }
 
+// TINYGO: IPConn uses a netdev SOCK_RAW socket.  Reads and writes on an
+// unconnected IPConn (from ListenIP) need a netdev supporting packetNetdever.
+
 // SyscallConn returns a raw network connection.
 // This implements the [syscall.Conn] interface.
 func (c *IPConn) SyscallConn() (syscall.RawConn, error) {
-	return nil, errors.New("SyscallConn not implemented")
+	if c.sock.closed.Load() {
+		return nil, &OpError{Op: "raw-conn", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: ErrClosed}
+	}
+	return &rawConn{fd: c.fd, net: c.net, laddr: c.laddr.opAddr(), raddr: c.raddr.opAddr(), sock: c.sock,
+		readDeadline: &c.readDeadline, writeDeadline: &c.writeDeadline}, nil
+}
+
+// Read implements the Conn Read method.
+func (c *IPConn) Read(b []byte) (int, error) {
+	if err := waitReadable(c.fd, c.readDeadline); err != nil {
+		return 0, &OpError{Op: "read", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	n, err := netdev.Recv(c.fd, b, 0, c.readDeadline)
+	// Turn the -1 socket error into 0 and let err speak for error
+	if n < 0 {
+		n = 0
+	}
+	c.sock.countRecv(n, err)
+	if err != nil && err != io.EOF {
+		err = &OpError{Op: "read", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	return n, err
+}
+
+// Write implements the Conn Write method.
+func (c *IPConn) Write(b []byte) (int, error) {
+	n, err := netdev.Send(c.fd, b, 0, c.writeDeadline)
+	// Turn the -1 socket error into 0 and let err speak for error
+	if n < 0 {
+		n = 0
+	}
+	c.sock.countSend(n, err)
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	return n, err
+}
+
+// readFrom receives a packet with its source address.  IPv4 headers are
+// stripped, as for upstream Go.
+func (c *IPConn) readFrom(b []byte) (int, *IPAddr, error) {
+	pn, ok := netdevExt[packetNetdever](c.fd)
+	if !ok {
+		return 0, nil, errors.ErrUnsupported
+	}
+	if err := waitReadable(c.fd, c.readDeadline); err != nil {
+		return 0, nil, err
+	}
+	n, from, err := pn.RecvFrom(c.fd, b, 0, c.readDeadline)
+	// Turn the -1 socket error into 0 and let err speak for error
+	if n < 0 {
+		n = 0
+	}
+	c.sock.countRecv(n, err)
+	if err != nil {
+		return n, nil, err
+	}
+	return stripIPv4Header(n, b), ipAddrFromAddr(from.Addr()), nil
+}
+
+func stripIPv4Header(n int, b []byte) int {
+	if len(b) < 20 {
+		return n
+	}
+	l := int(b[0]&0x0f) << 2
+	if 20 > l || l > len(b) {
+		return n
+	}
+	if b[0]>>4 != 4 {
+		return n
+	}
+	copy(b, b[l:])
+	return n - l
+}
+
+// ReadFromIP acts like ReadFrom but returns an IPAddr.
+func (c *IPConn) ReadFromIP(b []byte) (int, *IPAddr, error) {
+	n, addr, err := c.readFrom(b)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	return n, addr, err
+}
+
+// ReadFrom implements the [PacketConn] ReadFrom method.
+func (c *IPConn) ReadFrom(b []byte) (int, Addr, error) {
+	n, addr, err := c.readFrom(b)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	if addr == nil {
+		return n, nil, err
+	}
+	return n, addr, err
 }
 
 // ReadMsgIP reads a message from c, copying the payload into b and
@@ -110,22 +242,252 @@ func (c *IPConn) SyscallConn() (syscall.RawConn, error) {
 //
 // The packages golang.org/x/net/ipv4 and golang.org/x/net/ipv6 can be
 // used to manipulate IP-level socket options in oob.
+//
+// TINYGO: There is no out-of-band data; oobn and flags are always zero.
 func (c *IPConn) ReadMsgIP(b, oob []byte) (n, oobn, flags int, addr *IPAddr, err error) {
-	err = errors.New("ReadMsgIP not implemented")
+	n, addr, err = c.ReadFromIP(b)
 	return
 }
 
-// ReadFrom implements the PacketConn ReadFrom method.
-func (c *IPConn) ReadFrom(b []byte) (int, Addr, error) {
-	return 0, nil, errors.New("ReadFrom not implemented")
+// writeTo sends b to addr
+func (c *IPConn) writeTo(b []byte, addr *IPAddr) (int, error) {
+	if c.raddr != nil {
+		return 0, ErrWriteToConnected
+	}
+	if addr == nil {
+		return 0, errMissingAddress
+	}
+	pn, ok := netdevExt[packetNetdever](c.fd)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	ip, ok := netip.AddrFromSlice(addr.IP)
+	if !ok {
+		return 0, &AddrError{Err: "invalid IP address", Addr: addr.String()}
+	}
+	n, err := pn.SendTo(c.fd, b, 0, netip.AddrPortFrom(ip.Unmap(), 0), c.writeDeadline)
+	// Turn the -1 socket error into 0 and let err speak for error
+	if n < 0 {
+		n = 0
+	}
+	c.sock.countSend(n, err)
+	return n, err
 }
 
 // WriteToIP acts like WriteTo but takes an IPAddr.
 func (c *IPConn) WriteToIP(b []byte, addr *IPAddr) (int, error) {
-	return 0, errors.New("WriteToIP not implemented")
+	n, err := c.writeTo(b, addr)
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: addr.opAddr(), Err: err}
+	}
+	return n, err
 }
 
 // WriteTo implements the [PacketConn] WriteTo method.
 func (c *IPConn) WriteTo(b []byte, addr Addr) (int, error) {
-	return 0, errors.New("WriteTo not implemented")
+	a, ok := addr.(*IPAddr)
+	if !ok {
+		return 0, &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: addr, Err: &AddrError{Err: "not an IP address", Addr: addr.String()}}
+	}
+	return c.WriteToIP(b, a)
+}
+
+// WriteMsgIP writes a message to addr via c, copying the payload from
+// b and the associated out-of-band data from oob. It returns the number
+// of payload and out-of-band bytes written.
+//
+// The packages golang.org/x/net/ipv4 and golang.org/x/net/ipv6 can be
+// used to manipulate IP-level socket options in oob.
+//
+// TINYGO: Out-of-band data is not supported.
+func (c *IPConn) WriteMsgIP(b, oob []byte, addr *IPAddr) (n, oobn int, err error) {
+	if len(oob) > 0 {
+		return 0, 0, &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: addr.opAddr(), Err: errors.ErrUnsupported}
+	}
+	n, err = c.WriteToIP(b, addr)
+	return
+}
+
+// Close closes the connection.
+func (c *IPConn) Close() error {
+	return c.sock.close()
+}
+
+// Stats returns the traffic counters of the connection.
+func (c *IPConn) Stats() ConnStats {
+	return c.sock.connStats()
+}
+
+// LocalAddr returns the local network address.
+func (c *IPConn) LocalAddr() Addr {
+	return c.laddr.opAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *IPConn) RemoteAddr() Addr {
+	return c.raddr.opAddr()
+}
+
+// SetDeadline implements the Conn SetDeadline method.
+func (c *IPConn) SetDeadline(t time.Time) error {
+	c.readDeadline = t
+	c.writeDeadline = t
+	return nil
+}
+
+// SetReadDeadline implements the Conn SetReadDeadline method.
+func (c *IPConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline implements the Conn SetWriteDeadline method.
+func (c *IPConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline = t
+	return nil
+}
+
+// DialIP acts like [Dial] for IP networks.
+//
+// The network must be an IP network name; see func Dial for details.
+//
+// If laddr is nil, a local address is automatically chosen.
+// If the IP field of raddr is nil or an unspecified IP address, the
+// local system is assumed.
+func DialIP(network string, laddr, raddr *IPAddr) (*IPConn, error) {
+	return dialIP(context.Background(), defaultSocketTrace.Load(), nil, network, laddr, raddr)
+}
+
+func dialIP(ctx context.Context, trace *SocketTrace, ctrl controlFunc, network string, laddr, raddr *IPAddr) (*IPConn, error) {
+	if raddr == nil {
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: nil, Err: errMissingAddress}
+	}
+	afnet, proto, err := parseNetwork(ctx, network, true)
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: raddr.opAddr(), Err: err}
+	}
+	switch afnet {
+	case "ip", "ip4":
+	default:
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: raddr.opAddr(), Err: UnknownNetworkError(network)}
+	}
+
+	// TINYGO: Use netdev to create the raw socket and connect
+
+	if raddr.IP.IsUnspecified() {
+		return nil, errors.New("Sorry, localhost isn't available on Tinygo")
+	}
+	rip, ok := netip.AddrFromSlice(raddr.IP)
+	if !ok || !rip.Unmap().Is4() {
+		return nil, errors.New("only ipv4 supported")
+	}
+
+	start := time.Now()
+
+	fd, err := netdev.Socket(_AF_INET, _SOCK_RAW, proto)
+	trace.socketCreated(fd, network, err)
+	if err != nil {
+		countDial(start, err)
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: raddr, Err: err}
+	}
+	// Registered now so a hung connect shows in OpenSockets
+	sock := newSocket(fd, "IPConn", network, laddr.opAddr(), raddr, trace)
+
+	if err := runControl(ctrl, fd, raddr.String()); err != nil {
+		sock.close()
+		countDial(start, err)
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: raddr, Err: err}
+	}
+
+	if laddr != nil {
+		err = bindIP(trace, fd, laddr)
+		if err != nil {
+			sock.close()
+			countDial(start, err)
+			return nil, &OpError{Op: "dial", Net: network, Source: laddr, Addr: raddr, Err: err}
+		}
+	}
+
+	raddrport := netip.AddrPortFrom(rip.Unmap(), 0)
+	trace.connectStart(fd, network, raddr)
+	err = netdev.Connect(fd, "", raddrport)
+	trace.connectDone(fd, network, raddr, err)
+	if err != nil {
+		sock.close()
+		countDial(start, err)
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: raddr, Err: err}
+	}
+	countDial(start, nil)
+
+	return &IPConn{
+		fd:    fd,
+		net:   network,
+		laddr: laddr,
+		raddr: raddr,
+		sock:  sock,
+	}, nil
+}
+
+// bindIP binds fd to laddr.  A nil laddr.IP binds to the unspecified
+// address, as only IPv4 is supported.
+func bindIP(trace *SocketTrace, fd int, laddr *IPAddr) error {
+	lip, ok := netip.AddrFromSlice(laddr.IP)
+	if !ok {
+		lip = netip.IPv4Unspecified()
+	}
+	err := netdev.Bind(fd, netip.AddrPortFrom(lip.Unmap(), 0))
+	trace.bind(fd, laddr, err)
+	return err
+}
+
+// ListenIP acts like [ListenPacket] for IP networks.
+//
+// The network must be an IP network name; see func Dial for details.
+//
+// If the IP field of laddr is nil or an unspecified IP address,
+// ListenIP listens on all available IP addresses of the local system
+// except multicast IP addresses.
+func ListenIP(network string, laddr *IPAddr) (*IPConn, error) {
+	return listenIP(context.Background(), defaultSocketTrace.Load(), nil, network, laddr)
+}
+
+func listenIP(ctx context.Context, trace *SocketTrace, ctrl controlFunc, network string, laddr *IPAddr) (*IPConn, error) {
+	if laddr == nil {
+		laddr = &IPAddr{}
+	}
+	afnet, proto, err := parseNetwork(ctx, network, true)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+	switch afnet {
+	case "ip", "ip4":
+	default:
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: UnknownNetworkError(network)}
+	}
+
+	// TINYGO: Use netdev to create the raw socket and bind
+
+	fd, err := netdev.Socket(_AF_INET, _SOCK_RAW, proto)
+	trace.socketCreated(fd, network, err)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+	sock := newSocket(fd, "IPConn", network, laddr, nil, trace)
+
+	if err := runControl(ctrl, fd, laddr.String()); err != nil {
+		sock.close()
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+
+	if err := bindIP(trace, fd, laddr); err != nil {
+		sock.close()
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+
+	return &IPConn{
+		fd:    fd,
+		net:   network,
+		laddr: laddr,
+		sock:  sock,
+	}, nil
 }
@@ -0,0 +1,53 @@
+// Raw IP socket tests
+
+package net
+
+import (
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// rawNetdev is a netdev recording binds, whose Recv reports the peer
+// closed.
+type rawNetdev struct {
+	nopNetdev
+	bound netip.AddrPort
+}
+
+func (d *rawNetdev) Socket(domain int, stype int, protocol int) (int, error) {
+	return 3, nil
+}
+
+func (d *rawNetdev) Bind(sockfd int, ip netip.AddrPort) error {
+	d.bound = ip
+	return nil
+}
+
+func (d *rawNetdev) Recv(sockfd int, buf []byte, flags int, deadline time.Time) (int, error) {
+	return 0, io.EOF
+}
+
+func (d *rawNetdev) Close(sockfd int) error { return nil }
+
+func TestListenIPUnspecified(t *testing.T) {
+	dev := &rawNetdev{}
+	oldNetdev := netdev
+	netdev = dev
+	t.Cleanup(func() { netdev = oldNetdev })
+
+	c, err := ListenIP("ip4:icmp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if want := netip.AddrPortFrom(netip.IPv4Unspecified(), 0); dev.bound != want {
+		t.Errorf("bound to %v, want %v", dev.bound, want)
+	}
+
+	// io.EOF is passed through, as for TCPConn and UDPConn
+	if _, err := c.Read(make([]byte, 64)); err != io.EOF {
+		t.Errorf("Read = %v, want %v", err, io.EOF)
+	}
+}
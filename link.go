@@ -0,0 +1,99 @@
+// Link and address change notifications
+
+package net
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+)
+
+// ErrLinkDown is returned by Dial and DialContext while the netdev reports
+// its link down, instead of waiting for the connect to time out.
+var ErrLinkDown = errors.New("link down")
+
+// LinkEvent describes a change of the netdev's link state or address, as
+// reported by the netdev.
+type LinkEvent struct {
+	Up      bool       // link is up after the change
+	Addr    netip.Addr // address after the change; invalid if none
+	WasUp   bool       // link was up before the change
+	OldAddr netip.Addr // address before the change; invalid if none
+}
+
+// AddrChanged reports whether the event changed the address.
+func (e LinkEvent) AddrChanged() bool {
+	return e.Addr != e.OldAddr
+}
+
+// linkSub is a function registered with OnLinkChange
+type linkSub struct {
+	f func(LinkEvent)
+}
+
+var (
+	linkMu   sync.Mutex
+	linkUp   = true // assumed up until the netdev reports otherwise
+	linkAddr netip.Addr
+	linkSubs []*linkSub
+)
+
+// LinkState returns the link state and address last reported by the
+// netdev.  Until the netdev reports a change, or if it doesn't report
+// changes at all, the link is assumed up with no known address.
+func LinkState() (up bool, addr netip.Addr) {
+	linkMu.Lock()
+	defer linkMu.Unlock()
+	return linkUp, linkAddr
+}
+
+// OnLinkChange registers f to be called with each link or address change
+// the netdev reports, until stop is called.  The functions registered are
+// called in order, on the netdev's goroutine, so f must not block.
+//
+// Only netdevs reporting link changes trigger f; see LinkState.
+func OnLinkChange(f func(LinkEvent)) (stop func()) {
+	sub := &linkSub{f: f}
+	linkMu.Lock()
+	linkSubs = append(linkSubs, sub)
+	linkMu.Unlock()
+	return func() {
+		linkMu.Lock()
+		defer linkMu.Unlock()
+		for i, s := range linkSubs {
+			if s == sub {
+				linkSubs = append(linkSubs[:i:i], linkSubs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// linkChanged records the link state and address reported by the netdev,
+// and calls the OnLinkChange functions if either changed.
+func linkChanged(up bool, addr netip.Addr) {
+	if !up {
+		addr = netip.Addr{}
+	}
+	linkMu.Lock()
+	e := LinkEvent{Up: up, Addr: addr, WasUp: linkUp, OldAddr: linkAddr}
+	if e.Up == e.WasUp && !e.AddrChanged() {
+		linkMu.Unlock()
+		return
+	}
+	linkUp, linkAddr = up, addr
+	subs := linkSubs
+	linkMu.Unlock()
+
+	for _, s := range subs {
+		s.f(e)
+	}
+}
+
+// checkLink returns ErrLinkDown if the netdev reported its link down.
+func checkLink() error {
+	if up, _ := LinkState(); !up {
+		return ErrLinkDown
+	}
+	return nil
+}
@@ -10,6 +10,36 @@ import (
 	"errors"
 )
 
+// protocols contains minimal mappings between internet protocol
+// names and numbers for platforms that don't have a complete list of
+// protocol numbers.
+//
+// See https://www.iana.org/assignments/protocol-numbers
+//
+// TINYGO: There is no /etc/protocols; this map is all there is.
+var protocols = map[string]int{
+	"icmp":      1,
+	"igmp":      2,
+	"tcp":       6,
+	"udp":       17,
+	"ipv6-icmp": 58,
+}
+
+// maxProtoLength is the length of the longest protocol name in protocols + 1
+const maxProtoLength = len("RSVP-E2E-IGNORE") + 10 // with room to grow
+
+// lookupProtocolMap looks up a protocol name in the protocols map.
+func lookupProtocolMap(name string) (int, error) {
+	var lowerProtocol [maxProtoLength]byte
+	n := copy(lowerProtocol[:], name)
+	lowerASCIIBytes(lowerProtocol[:n])
+	proto, found := protocols[string(lowerProtocol[:n])]
+	if !found || n != len(name) {
+		return 0, &AddrError{Err: "unknown IP protocol specified", Addr: name}
+	}
+	return proto, nil
+}
+
 // LookupPort looks up the port for the given network and service.
 //
 // LookupPort uses [context.Background] internally; to specify the context, use
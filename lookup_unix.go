@@ -10,11 +10,11 @@ package net
 
 import (
 	"context"
-	"errors"
 )
 
 // lookupProtocol looks up IP protocol name in /etc/protocols and
 // returns correspondent protocol number.
 func lookupProtocol(_ context.Context, name string) (int, error) {
-	return 0, errors.New("net:lookupProtocol not implemented")
+	// TINYGO: Only the builtin protocols map
+	return lookupProtocolMap(name)
 }
@@ -8,10 +8,10 @@ package net
 
 import (
 	"context"
-	"errors"
 )
 
 // lookupProtocol looks up IP protocol name and returns correspondent protocol number.
 func lookupProtocol(ctx context.Context, name string) (int, error) {
-	return 0, errors.New("net:lookupProtocol not implemented")
+	// TINYGO: Only the builtin protocols map
+	return lookupProtocolMap(name)
 }
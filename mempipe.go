@@ -0,0 +1,221 @@
+// Buffered in-memory pipes
+
+package net
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// memBufferSize is the default capacity of a memQueue, in bytes
+const memBufferSize = 4096
+
+var errMsgTooLong = errors.New("message too long")
+
+// memQueue is one direction of an in-memory connection: a bounded buffer
+// of bytes for streams, or of messages for packet sockets.  Unlike Pipe,
+// writers don't wait for readers as long as the buffer has room.
+type memQueue struct {
+	mu      sync.Mutex
+	packet  bool
+	buf     []byte   // stream data
+	msgs    []memMsg // packets
+	size    int      // bytes queued
+	limit   int      // max bytes queued
+	eof     bool     // no more writes; reads drain then return io.EOF
+	broken  bool     // no more reads; writes fail
+	changed chan struct{}
+}
+
+type memMsg struct {
+	b    []byte
+	from Addr
+}
+
+func newMemQueue(packet bool) *memQueue {
+	return &memQueue{packet: packet, limit: memBufferSize, changed: make(chan struct{})}
+}
+
+// signal wakes all waiters.  q.mu must be held.
+func (q *memQueue) signal() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// setLimit changes the capacity of the queue.  Like setsockopt buffer
+// sizes, n is raised to a minimum, of 1 byte, as writes to a queue without
+// room would block forever.
+func (q *memQueue) setLimit(n int) {
+	q.mu.Lock()
+	q.limit = max(n, 1)
+	q.signal()
+	q.mu.Unlock()
+}
+
+// closeWrite makes reads return io.EOF once the queue is drained
+func (q *memQueue) closeWrite() {
+	q.mu.Lock()
+	q.eof = true
+	q.signal()
+	q.mu.Unlock()
+}
+
+// closeRead discards queued data and makes writes fail
+func (q *memQueue) closeRead() {
+	q.mu.Lock()
+	q.broken = true
+	q.buf, q.msgs, q.size = nil, nil, 0
+	q.signal()
+	q.mu.Unlock()
+}
+
+// read reads queued data into b, waiting for some if the queue is empty.
+// A packet larger than b is truncated.  from is the sender of the packet.
+func (q *memQueue) read(b []byte, deadline *pipeDeadline, done <-chan struct{}) (n int, from Addr, err error) {
+	for {
+		q.mu.Lock()
+		switch {
+		case q.packet && len(q.msgs) > 0:
+			m := q.msgs[0]
+			q.msgs[0] = memMsg{}
+			q.msgs = q.msgs[1:]
+			q.size -= len(m.b)
+			q.signal()
+			q.mu.Unlock()
+			return copy(b, m.b), m.from, nil
+		case !q.packet && len(q.buf) > 0:
+			n = copy(b, q.buf)
+			q.buf = q.buf[:copy(q.buf, q.buf[n:])]
+			q.size -= n
+			q.signal()
+			q.mu.Unlock()
+			return n, nil, nil
+		case q.eof || q.broken:
+			q.mu.Unlock()
+			return 0, nil, io.EOF
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline.wait():
+			return 0, nil, os.ErrDeadlineExceeded
+		case <-done:
+			return 0, nil, io.ErrClosedPipe
+		}
+	}
+}
+
+// write queues b, waiting for room if the queue is full.  A stream write
+// may be queued in parts; a packet is queued whole, from from.
+func (q *memQueue) write(b []byte, from Addr, deadline *pipeDeadline, done <-chan struct{}) (n int, err error) {
+	for {
+		q.mu.Lock()
+		if q.broken || q.eof {
+			q.mu.Unlock()
+			return n, io.ErrClosedPipe
+		}
+		room := q.limit - q.size
+		if q.packet {
+			if len(b) > q.limit {
+				q.mu.Unlock()
+				return 0, errMsgTooLong
+			}
+			if len(b) <= room {
+				q.msgs = append(q.msgs, memMsg{append([]byte(nil), b...), from})
+				q.size += len(b)
+				q.signal()
+				q.mu.Unlock()
+				return len(b), nil
+			}
+		} else if room > 0 {
+			m := min(room, len(b))
+			q.buf = append(q.buf, b[:m]...)
+			q.size += m
+			n += m
+			b = b[m:]
+			q.signal()
+			if len(b) == 0 {
+				q.mu.Unlock()
+				return n, nil
+			}
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline.wait():
+			return n, os.ErrDeadlineExceeded
+		case <-done:
+			return n, io.ErrClosedPipe
+		}
+	}
+}
+
+// memEndpoint is one end of an in-memory connection.  Reads come from rq
+// and writes go to wq, the peer's rq.  An unconnected datagram endpoint
+// has no wq.
+type memEndpoint struct {
+	rq, wq *memQueue
+
+	readDeadline  pipeDeadline
+	writeDeadline pipeDeadline
+
+	once sync.Once
+	done chan struct{} // closed by close
+}
+
+func newMemEndpoint(rq, wq *memQueue) *memEndpoint {
+	return &memEndpoint{
+		rq:            rq,
+		wq:            wq,
+		readDeadline:  makePipeDeadline(),
+		writeDeadline: makePipeDeadline(),
+		done:          make(chan struct{}),
+	}
+}
+
+// newMemPair returns the two connected ends of a buffered in-memory
+// connection.
+func newMemPair(packet bool) (*memEndpoint, *memEndpoint) {
+	q1, q2 := newMemQueue(packet), newMemQueue(packet)
+	return newMemEndpoint(q1, q2), newMemEndpoint(q2, q1)
+}
+
+func (e *memEndpoint) closed() bool {
+	return isClosedChan(e.done)
+}
+
+func (e *memEndpoint) read(b []byte) (int, Addr, error) {
+	if e.closed() {
+		return 0, nil, io.ErrClosedPipe
+	}
+	return e.rq.read(b, &e.readDeadline, e.done)
+}
+
+// writeTo writes b to q, which is e.wq for a connected endpoint
+func (e *memEndpoint) writeTo(q *memQueue, b []byte, from Addr) (int, error) {
+	if e.closed() {
+		return 0, io.ErrClosedPipe
+	}
+	return q.write(b, from, &e.writeDeadline, e.done)
+}
+
+// close shuts down both directions and wakes blocked reads and writes.
+// Only the first call returns true.
+func (e *memEndpoint) close() bool {
+	first := false
+	e.once.Do(func() {
+		first = true
+		close(e.done)
+		e.rq.closeRead()
+		if e.wq != nil {
+			e.wq.closeWrite()
+		}
+	})
+	return first
+}
@@ -0,0 +1,182 @@
+// Buffered in-memory pipe tests
+
+package net
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemQueueStream(t *testing.T) {
+	e1, e2 := newMemPair(false)
+	defer e1.close()
+	defer e2.close()
+
+	// Writes don't wait for reads while the buffer has room
+	for _, s := range []string{"hello, ", "world"} {
+		if n, err := e1.writeTo(e1.wq, []byte(s), nil); n != len(s) || err != nil {
+			t.Fatalf("write(%q) = %d, %v", s, n, err)
+		}
+	}
+	b := make([]byte, 5)
+	var got []byte
+	for len(got) < 12 {
+		n, _, err := e2.read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, b[:n]...)
+	}
+	if string(got) != "hello, world" {
+		t.Errorf("read %q, want %q", got, "hello, world")
+	}
+}
+
+func TestMemQueuePacket(t *testing.T) {
+	e1, e2 := newMemPair(true)
+	defer e1.close()
+	defer e2.close()
+
+	from := &UnixAddr{Name: "from", Net: "unixpacket"}
+	for _, s := range []string{"one", "two", "three"} {
+		if _, err := e1.writeTo(e1.wq, []byte(s), from); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := make([]byte, 16)
+	for _, want := range []string{"one", "two"} {
+		n, addr, err := e2.read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b[:n]) != want || addr != from {
+			t.Errorf("read %q from %v, want %q from %v", b[:n], addr, want, from)
+		}
+	}
+	// A packet larger than the buffer is truncated
+	n, _, err := e2.read(b[:2])
+	if n != 2 || err != nil || string(b[:n]) != "th" {
+		t.Errorf("truncated read = %q, %v, want %q", b[:n], err, "th")
+	}
+
+	if _, err := e1.writeTo(e1.wq, make([]byte, memBufferSize+1), nil); err != errMsgTooLong {
+		t.Errorf("oversized write = %v, want %v", err, errMsgTooLong)
+	}
+}
+
+func TestMemQueueLimit(t *testing.T) {
+	e1, e2 := newMemPair(false)
+	defer e1.close()
+	defer e2.close()
+
+	e1.wq.setLimit(4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if n, err := e1.writeTo(e1.wq, []byte("abcdefgh"), nil); n != 8 || err != nil {
+			t.Errorf("write = %d, %v, want 8, nil", n, err)
+		}
+	}()
+	var got []byte
+	b := make([]byte, 8)
+	for len(got) < 8 {
+		n, _, err := e2.read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 4 {
+			t.Errorf("read %d bytes past the limit of 4", n)
+		}
+		got = append(got, b[:n]...)
+	}
+	<-done
+	if string(got) != "abcdefgh" {
+		t.Errorf("read %q, want %q", got, "abcdefgh")
+	}
+
+	// A zero limit still lets writes through, a byte at a time
+	e1.wq.setLimit(0)
+	go func() {
+		io.ReadFull(readerFunc(func(b []byte) (int, error) {
+			n, _, err := e2.read(b)
+			return n, err
+		}), make([]byte, 3))
+	}()
+	e1.writeDeadline.set(time.Now().Add(time.Second))
+	if n, err := e1.writeTo(e1.wq, []byte("xyz"), nil); n != 3 || err != nil {
+		t.Errorf("write with zero limit = %d, %v, want 3, nil", n, err)
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(b []byte) (int, error) { return f(b) }
+
+func TestMemQueueDeadline(t *testing.T) {
+	e1, e2 := newMemPair(false)
+	defer e1.close()
+	defer e2.close()
+
+	e2.readDeadline.set(time.Now().Add(10 * time.Millisecond))
+	if _, _, err := e2.read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("read = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+
+	e1.wq.setLimit(1)
+	e1.writeDeadline.set(time.Now().Add(10 * time.Millisecond))
+	n, err := e1.writeTo(e1.wq, []byte("ab"), nil)
+	if n != 1 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("write = %d, %v, want 1, %v", n, err, os.ErrDeadlineExceeded)
+	}
+}
+
+func TestMemQueueClose(t *testing.T) {
+	e1, e2 := newMemPair(false)
+	defer e2.close()
+
+	e1.writeTo(e1.wq, []byte("bye"), nil)
+	e1.close()
+
+	// Data queued before close is still read, then io.EOF
+	b, err := io.ReadAll(readerFunc(func(b []byte) (int, error) {
+		n, _, err := e2.read(b)
+		return n, err
+	}))
+	if err != nil || !bytes.Equal(b, []byte("bye")) {
+		t.Errorf("read after peer close = %q, %v, want %q, nil", b, err, "bye")
+	}
+	if _, err := e2.writeTo(e2.wq, []byte("x"), nil); err != io.ErrClosedPipe {
+		t.Errorf("write to closed peer = %v, want %v", err, io.ErrClosedPipe)
+	}
+	if _, _, err := e1.read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Errorf("read after close = %v, want %v", err, io.ErrClosedPipe)
+	}
+	if e1.close() {
+		t.Errorf("second close reported true")
+	}
+}
+
+func TestMemQueueCloseWakesReader(t *testing.T) {
+	e1, e2 := newMemPair(false)
+	defer e2.close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := e1.read(make([]byte, 1))
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	e1.close()
+	select {
+	case err := <-errc:
+		if err != io.ErrClosedPipe && err != io.EOF {
+			t.Errorf("read = %v, want %v", err, io.ErrClosedPipe)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("close didn't wake read")
+	}
+}
@@ -12,17 +12,50 @@ const (
 	_AF_INET       = 0x2
 	_SOCK_STREAM   = 0x1
 	_SOCK_DGRAM    = 0x2
+	_SOCK_RAW      = 0x3
 	_SOL_SOCKET    = 0x1
+	_SO_SNDBUF     = 0x7
+	_SO_RCVBUF     = 0x8
 	_SO_KEEPALIVE  = 0x9
 	_SO_LINGER     = 0xd
 	_SOL_TCP       = 0x6
+	_TCP_NODELAY   = 0x1
+	_TCP_KEEPIDLE  = 0x4
 	_TCP_KEEPINTVL = 0x5
+	_TCP_KEEPCNT   = 0x6
 	_IPPROTO_TCP   = 0x6
 	_IPPROTO_UDP   = 0x11
 	// Made up, not a real IP protocol number.  This is used to create a
 	// TLS socket on the device, assuming the device supports mbed TLS.
 	_IPPROTO_TLS = 0xFE
 	_F_SETFL     = 0x4
+
+	// TLS options, set on _IPPROTO_TLS sockets before Connect
+	_SOL_TLS                  = _IPPROTO_TLS
+	_TLS_SERVER_NAME          = 0x1
+	_TLS_ROOT_CAS             = 0x2
+	_TLS_CERTIFICATE          = 0x3
+	_TLS_PRIVATE_KEY          = 0x4
+	_TLS_MIN_VERSION          = 0x5
+	_TLS_MAX_VERSION          = 0x6
+	_TLS_INSECURE_SKIP_VERIFY = 0x7
+	_TLS_ALPN                 = 0x8
+	_TLS_CLIENT_CAS           = 0x9
+	_TLS_CLIENT_AUTH          = 0xa
+
+	// Shutdown how argument
+	_SHUT_RD   = 0x0
+	_SHUT_WR   = 0x1
+	_SHUT_RDWR = 0x2
+
+	// Poll events
+	_POLLIN  = 0x1
+	_POLLOUT = 0x4
+	_POLLERR = 0x8
+	_POLLHUP = 0x10
+
+	// Socket option binding a socket to one of several interfaces
+	_SO_BINDTODEVICE = 0x19
 )
 
 // netdev is the current netdev, set by the application with useNetdev().
@@ -34,6 +67,9 @@ var netdev netdever = &nopNetdev{}
 // (useNetdev is go:linkname'd from tinygo/drivers package)
 func useNetdev(dev netdever) {
 	netdev = dev
+	if n, ok := dev.(linkNotifier); ok {
+		n.NotifyLink(linkChanged)
+	}
 }
 
 // netdever is TinyGo's OSI L3/L4 network/transport layer interface.  Network
@@ -95,6 +131,10 @@ type netdever interface {
 	// this is not C we may use a error type native to Go to represent the error
 	// ocurred which by itself not only notifies of an error but also provides
 	// information on the error as a human readable string when calling the Error method.
+	//
+	// A device without TLS offload must fail Socket for the IPPROTO_TLS
+	// protocol with an error matching errors.ErrUnsupported or
+	// syscall.EPROTONOSUPPORT, for DialTLS to fall back to software TLS.
 	Socket(domain int, stype int, protocol int) (sockfd int, _ error)
 	Bind(sockfd int, ip netip.AddrPort) error
 	Connect(sockfd int, host string, ip netip.AddrPort) error
@@ -141,9 +181,164 @@ type netdever interface {
 	// which the value for the requested option(s) are to be returned.
 	// In Go we provide developers with an `any` interface to be able
 	// to pass driver-specific configurations.
+	//
+	// # Options used by the net package
+	//
+	// The net package sets these options, with these value types:
+	//
+	//	Level        Option          Type           Meaning
+	//	SOL_SOCKET   SO_KEEPALIVE    bool           enable keep-alive probes
+	//	SOL_SOCKET   SO_LINGER       int            linger seconds on Close, <0 to disable
+	//	SOL_SOCKET   SO_RCVBUF       int            receive buffer size, in bytes
+	//	SOL_SOCKET   SO_SNDBUF       int            send buffer size, in bytes
+	//	SOL_SOCKET   SO_BINDTODEVICE string         interface name, set before Bind or Connect
+	//	SOL_TCP      TCP_NODELAY     bool           disable Nagle's algorithm
+	//	SOL_TCP      TCP_KEEPIDLE    time.Duration  idle time before the first probe
+	//	SOL_TCP      TCP_KEEPINTVL   time.Duration  time between probes
+	//	SOL_TCP      TCP_KEEPINTVL   float64        keep-alive period, in half-seconds
+	//	SOL_TCP      TCP_KEEPCNT     int            unanswered probes before drop
+	//
+	// TCP_KEEPINTVL is set as a float64 by SetKeepAlivePeriod, as it
+	// always was, and as a time.Duration, along with TCP_KEEPIDLE and
+	// TCP_KEEPCNT, by SetKeepAliveConfig.
+	//
+	// SO_BINDTODEVICE is only set if Dialer.Interface or
+	// ListenConfig.Interface is set.
+	//
+	// On IPPROTO_TLS sockets, DialTLSConfig sets these options between
+	// Socket and Connect, and ListenTLS between Socket and Bind, from a
+	// TLSConfig.  Options left at their zero value in the TLSConfig are
+	// not set.
+	//
+	//	Level     Option                    Type      Meaning
+	//	SOL_TLS   TLS_SERVER_NAME           string    SNI and name to verify, if not the Connect host
+	//	SOL_TLS   TLS_ROOT_CAS              [][]byte  DER certificates to verify the server with
+	//	SOL_TLS   TLS_CERTIFICATE           [][]byte  DER certificate chain to present, leaf first
+	//	SOL_TLS   TLS_PRIVATE_KEY           []byte    PKCS #8 DER private key of the certificate
+	//	SOL_TLS   TLS_MIN_VERSION           uint16    minimum TLS version, e.g. 0x0303 for TLS 1.2
+	//	SOL_TLS   TLS_MAX_VERSION           uint16    maximum TLS version
+	//	SOL_TLS   TLS_INSECURE_SKIP_VERIFY  bool      don't verify the server certificate
+	//	SOL_TLS   TLS_ALPN                  []string  ALPN protocols, in order of preference
+	//	SOL_TLS   TLS_CLIENT_CAS            [][]byte  DER certificates to verify clients with (server)
+	//	SOL_TLS   TLS_CLIENT_AUTH           int       client certificate policy, as crypto/tls ClientAuthType (server)
+	//
+	// Accepted sockets of a listening IPPROTO_TLS socket are TLS server
+	// connections.
+	//
+	// Drivers should round durations to the unit their device supports,
+	// and return an error for options they don't support.  A driver
+	// unable to honor a TLS option must return an error rather than
+	// ignore it, as ignoring e.g. TLS_ROOT_CAS would weaken security.
 	SetSockOpt(sockfd int, level int, opt int, value interface{}) error
 }
 
+// sockOptGetter is an optional netdever extension for devices able to read
+// back socket options.  GetSockOpt returns the value of an option of the
+// socket sockfd; levels, options and value types are those of SetSockOpt.
+type sockOptGetter interface {
+	GetSockOpt(sockfd int, level int, opt int) (interface{}, error)
+}
+
+// shutdowner is an optional netdever extension for devices supporting
+// half-close.  Shutdown shuts down the receiving (_SHUT_RD), sending
+// (_SHUT_WR) or both (_SHUT_RDWR) directions of a connected socket, like
+// shutdown(2).  The socket must still be closed with Close.
+type shutdowner interface {
+	Shutdown(sockfd int, how int) error
+}
+
+// shutdown shuts down part of a full-duplex connection, if the netdev
+// supports it.
+func shutdown(fd int, how int) error {
+	if s, ok := netdevExt[shutdowner](fd); ok {
+		return s.Shutdown(fd, how)
+	}
+	return errors.ErrUnsupported
+}
+
+// buffersSender is an optional netdever extension for devices able to send
+// several buffers with one call (scatter/gather), like writev(2).  The
+// buffers of a datagram socket are sent as one datagram.  SendBuffers
+// returns the total number of bytes sent.
+type buffersSender interface {
+	SendBuffers(sockfd int, bufs [][]byte, flags int, deadline time.Time) (int, error)
+}
+
+// bufferReceiver is an optional netdever extension for devices able to lend
+// their receive buffer to the caller, saving a copy.  RecvBuffer waits for
+// received data like Recv, then calls consume with the data, in one or
+// more chunks.  The chunks are only valid during the call to consume.
+// consume returns how many bytes it used; unused bytes stay queued for the
+// next receive.  RecvBuffer stops at the first error from consume and
+// returns it, along with the total number of bytes consumed.
+type bufferReceiver interface {
+	RecvBuffer(sockfd int, flags int, deadline time.Time, consume func(b []byte) (int, error)) (int, error)
+}
+
+// packetNetdever is an optional netdever extension for devices supporting
+// unconnected datagram and raw sockets.  RecvFrom is Recv also returning
+// the source address of the packet, and SendTo is Send to the destination
+// to.  On SOCK_RAW sockets the port of the addresses is zero, and received
+// IPv4 packets start with the IP header, like on Linux.
+type packetNetdever interface {
+	RecvFrom(sockfd int, buf []byte, flags int, deadline time.Time) (int, netip.AddrPort, error)
+	SendTo(sockfd int, buf []byte, flags int, to netip.AddrPort, deadline time.Time) (int, error)
+}
+
+// netdevPoller is an optional netdever extension for devices able to wait
+// for readiness on many sockets at once, like poll(2).  For each fds[i],
+// Poll waits for the _POLLIN/_POLLOUT events in events[i] and sets the
+// events ready, including _POLLERR and _POLLHUP, in revents[i].  Poll
+// returns the number of fds with events ready, or 0 if deadline passed
+// first.  A zero deadline means no deadline.
+type netdevPoller interface {
+	Poll(fds []int, events []int, revents []int, deadline time.Time) (int, error)
+}
+
+// linkNotifier is an optional netdever extension for devices reporting link
+// and address changes, e.g. Wi-Fi dropping and reconnecting, or DHCP
+// assigning a new address.  NotifyLink registers notify, which the device
+// then calls each time its link goes up or down or its address changes,
+// with the new state.  addr is the zero Addr while the device has no
+// address.  notify runs the OnLinkChange functions, which may call back
+// into the netdev, e.g. to close sockets, so the device must not hold its
+// own locks while calling notify.
+type linkNotifier interface {
+	NotifyLink(notify func(up bool, addr netip.Addr))
+}
+
+// fdNetdever is implemented by netdevs passing sockets on to other netdevs,
+// such as the failover netdev of UseFailover.  fdNetdev returns the netdev
+// serving the socket fd, or nil if there is none.
+type fdNetdever interface {
+	fdNetdev(fd int) netdever
+}
+
+// netdevExt returns the netdev as the optional extension T, if the netdev
+// supports T for the socket fd.  Extensions with socket arguments are
+// looked up with netdevExt rather than asserted on the netdev, as the
+// netdevs behind an fdNetdever may differ in the extensions they support.
+func netdevExt[T any](fd int) (T, bool) {
+	if fn, ok := netdev.(fdNetdever); ok {
+		if _, ok := fn.fdNetdev(fd).(T); !ok {
+			var zero T
+			return zero, false
+		}
+	}
+	x, ok := netdev.(T)
+	return x, ok
+}
+
+// netdevAddr returns the address of the netdev serving the socket fd.
+func netdevAddr(fd int) (netip.Addr, error) {
+	if fn, ok := netdev.(fdNetdever); ok {
+		if dev := fn.fdNetdev(fd); dev != nil {
+			return dev.Addr()
+		}
+	}
+	return netdev.Addr()
+}
+
 var ErrNetdevNotSet = errors.New("Netdev not set")
 
 // nopNetdev is a NOP netdev that errors out any interface calls
@@ -0,0 +1,252 @@
+// Package netlog provides net.Conn and net.Listener wrappers that log
+// connection activity as structured log/slog records.
+//
+// Each Read, Write, deadline change and Close on a wrapped connection
+// produces one record with the connection name, the operation and its
+// result.  Data read and written can optionally be hex dumped, with a size
+// limit and a redaction hook to keep secrets out of the logs.
+//
+// To log the connections of an HTTP server:
+//
+//	srv := &http.Server{WrapConn: netlog.Wrapper(&netlog.Options{Name: "server"})}
+//
+// To log the connections of an HTTP client:
+//
+//	tr := &http.Transport{DialContext: netlog.DialContext(nil, &netlog.Options{Name: "client"})}
+//	client := &http.Client{Transport: tr}
+package netlog
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMaxDump is the default limit of bytes hex dumped per Read or Write.
+const DefaultMaxDump = 64
+
+// Options configure the logging of a connection.  The zero Options log at
+// slog.LevelDebug to slog.Default() without hex dumps.
+type Options struct {
+	// Logger receives the log records.  If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// Level is the level of the log records.  Records of operations
+	// failing with an error other than io.EOF are logged at
+	// slog.LevelError or Level, whichever is higher.
+	Level slog.Level
+
+	// Name is the base name of the connections.  Each connection is
+	// logged with a "conn" attribute made of Name and a sequence
+	// number, e.g. "server-3".  If empty, "conn" is used.
+	Name string
+
+	// HexDump enables hex dumps of the data read and written.
+	HexDump bool
+
+	// MaxDump limits the number of bytes dumped per Read or Write.
+	// If zero, DefaultMaxDump is used.  If negative, there is no limit.
+	MaxDump int
+
+	// Redact, if not nil, is called with the data of each Read or Write
+	// before it is dumped; the returned bytes are dumped instead.  op is
+	// "read" or "write".  Redact must not modify b.
+	Redact func(op string, b []byte) []byte
+}
+
+var (
+	seqMu sync.Mutex
+	seq   = make(map[string]int)
+)
+
+// uniqName returns base with a sequence number unique to base appended
+func uniqName(base string) string {
+	seqMu.Lock()
+	defer seqMu.Unlock()
+	seq[base]++
+	return base + "-" + strconv.Itoa(seq[base])
+}
+
+// Conn is a net.Conn logging the operations on the net.Conn it wraps.
+type Conn struct {
+	net.Conn
+	name   string
+	logger *slog.Logger
+	opts   Options
+}
+
+// NewConn returns c wrapped in a Conn logging its operations.  A nil opts
+// is the same as the zero Options.
+func NewConn(c net.Conn, opts *Options) *Conn {
+	lc := &Conn{Conn: c}
+	if opts != nil {
+		lc.opts = *opts
+	}
+	lc.logger = lc.opts.Logger
+	if lc.logger == nil {
+		lc.logger = slog.Default()
+	}
+	base := lc.opts.Name
+	if base == "" {
+		base = "conn"
+	}
+	lc.name = uniqName(base)
+	lc.log("open", nil,
+		slog.Any("local", c.LocalAddr()),
+		slog.Any("remote", c.RemoteAddr()))
+	return lc
+}
+
+// Name returns the name the connection is logged with.
+func (c *Conn) Name() string { return c.name }
+
+// Unwrap returns the wrapped connection.
+func (c *Conn) Unwrap() net.Conn { return c.Conn }
+
+func (c *Conn) log(op string, err error, attrs ...slog.Attr) {
+	level := c.opts.Level
+	// io.EOF is the peer closing the connection, not a failure
+	if err != nil && err != io.EOF && level < slog.LevelError {
+		level = slog.LevelError
+	}
+	ctx := context.Background()
+	if !c.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs = append(attrs, slog.String("conn", c.name), slog.String("op", op))
+	if err != nil {
+		attrs = append(attrs, slog.Any("err", err))
+	}
+	c.logger.LogAttrs(ctx, level, "netlog "+op, attrs...)
+}
+
+func (c *Conn) logIO(op string, b []byte, n int, err error) {
+	if !c.opts.HexDump || n <= 0 {
+		c.log(op, err, slog.Int("len", len(b)), slog.Int("n", n))
+		return
+	}
+	data := b[:n]
+	if c.opts.Redact != nil {
+		data = c.opts.Redact(op, data)
+	}
+	max := c.opts.MaxDump
+	if max == 0 {
+		max = DefaultMaxDump
+	}
+	truncated := false
+	if max > 0 && len(data) > max {
+		data, truncated = data[:max], true
+	}
+	c.log(op, err, slog.Int("len", len(b)), slog.Int("n", n),
+		slog.String("dump", hex.Dump(data)),
+		slog.Bool("truncated", truncated))
+}
+
+// Read reads from the wrapped connection, logging the result.
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.logIO("read", b, n, err)
+	return n, err
+}
+
+// Write writes to the wrapped connection, logging the result.
+func (c *Conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.logIO("write", b, n, err)
+	return n, err
+}
+
+// Close closes the wrapped connection, logging the result.
+func (c *Conn) Close() error {
+	err := c.Conn.Close()
+	c.log("close", err)
+	return err
+}
+
+// CloseWrite shuts down the writing side of the wrapped connection, if it
+// supports half-close.
+func (c *Conn) CloseWrite() error {
+	cw, ok := c.Conn.(interface{ CloseWrite() error })
+	if !ok {
+		return &net.OpError{Op: "close", Source: c.LocalAddr(),
+			Addr: c.RemoteAddr(), Err: errors.ErrUnsupported}
+	}
+	err := cw.CloseWrite()
+	c.log("closewrite", err)
+	return err
+}
+
+// SetDeadline sets the deadlines of the wrapped connection, logging the
+// result.
+func (c *Conn) SetDeadline(t time.Time) error {
+	err := c.Conn.SetDeadline(t)
+	c.log("setdeadline", err, slog.Time("deadline", t))
+	return err
+}
+
+// SetReadDeadline sets the read deadline of the wrapped connection,
+// logging the result.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	err := c.Conn.SetReadDeadline(t)
+	c.log("setreaddeadline", err, slog.Time("deadline", t))
+	return err
+}
+
+// SetWriteDeadline sets the write deadline of the wrapped connection,
+// logging the result.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	err := c.Conn.SetWriteDeadline(t)
+	c.log("setwritedeadline", err, slog.Time("deadline", t))
+	return err
+}
+
+// Wrapper returns a function wrapping connections in a Conn with opts,
+// suitable for http.Server.WrapConn.
+func Wrapper(opts *Options) func(net.Conn) net.Conn {
+	return func(c net.Conn) net.Conn {
+		return NewConn(c, opts)
+	}
+}
+
+// DialContext returns a dial function that dials with dial and wraps the
+// connections in a Conn with opts, suitable for http.Transport.DialContext.
+// If dial is nil, a zero net.Dialer is used.
+func DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error),
+	opts *Options) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return NewConn(c, opts), nil
+	}
+}
+
+type listener struct {
+	net.Listener
+	opts *Options
+}
+
+// NewListener returns l wrapped in a net.Listener which wraps the accepted
+// connections in a Conn with opts.
+func NewListener(l net.Listener, opts *Options) net.Listener {
+	return &listener{Listener: l, opts: opts}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(c, l.opts), nil
+}
@@ -0,0 +1,43 @@
+// ICMP echo offload
+
+package net
+
+import (
+	"errors"
+	"net/netip"
+	"time"
+)
+
+// netdevPinger is an optional netdever extension for devices with a
+// built-in ICMP echo (ping) client, typically a co-processor firmware
+// command.  Ping sends one echo request with size bytes of payload to ip
+// and waits for the reply until deadline.  It returns the round-trip time
+// and the TTL of the reply, or 0 if the device doesn't report it.
+type netdevPinger interface {
+	Ping(ip netip.Addr, size int, deadline time.Time) (rtt time.Duration, ttl int, err error)
+}
+
+// PingOffload sends one ICMP echo request to ip with size bytes of payload
+// using the netdev's built-in ping, and waits up to timeout for the reply.
+// It returns the round-trip time and the TTL of the reply, or 0 if the
+// netdev doesn't report the TTL.
+//
+// If the netdev has no built-in ping, the error wraps errors.ErrUnsupported;
+// use raw IP sockets instead (see DialIP), or the net/ping package which
+// picks whichever is available.
+func PingOffload(ip IP, size int, timeout time.Duration) (rtt time.Duration, ttl int, err error) {
+	addr := &IPAddr{IP: ip}
+	p, ok := netdev.(netdevPinger)
+	if !ok {
+		return 0, 0, &OpError{Op: "ping", Net: "ip4:icmp", Addr: addr, Err: errors.ErrUnsupported}
+	}
+	nip, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return 0, 0, &OpError{Op: "ping", Net: "ip4:icmp", Addr: addr, Err: &AddrError{Err: "invalid IP address", Addr: ip.String()}}
+	}
+	rtt, ttl, err = p.Ping(nip.Unmap(), size, time.Now().Add(timeout))
+	if err != nil {
+		return 0, 0, &OpError{Op: "ping", Net: "ip4:icmp", Addr: addr, Err: err}
+	}
+	return rtt, ttl, nil
+}
@@ -0,0 +1,332 @@
+// Package ping sends ICMP echo requests (pings) and reports round-trip
+// time, loss and TTL statistics.
+//
+// Pings are sent over a raw "ip4:icmp" IPConn if the netdev supports raw
+// sockets, or else with the netdev's built-in ping, if it has one:
+//
+//	p := &ping.Pinger{Addr: "192.168.1.1", Count: 4}
+//	stats, err := p.Run(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	println("loss", stats.Loss(), "% avg", stats.AvgRTT.String())
+package ping
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Method selects how echo requests are sent.
+type Method int
+
+const (
+	// Auto uses a raw IP socket if the netdev supports it, or else the
+	// netdev's built-in ping.
+	Auto Method = iota
+	// Raw uses a raw "ip4:icmp" IP socket.
+	Raw
+	// Offload uses the netdev's built-in ping; see net.PingOffload.
+	Offload
+)
+
+const (
+	defaultCount    = 4
+	defaultInterval = time.Second
+	defaultTimeout  = time.Second
+	defaultSize     = 32
+)
+
+// A Pinger sends a series of ICMP echo requests to a host.
+type Pinger struct {
+	// Addr is the host name or IPv4 address to ping.
+	Addr string
+
+	// Count is the number of echo requests to send.  If zero, 4
+	// requests are sent.
+	Count int
+
+	// Interval is the time between echo requests.  If zero, one second.
+	Interval time.Duration
+
+	// Timeout is how long to wait for each reply.  If zero, one second.
+	Timeout time.Duration
+
+	// Size is the number of payload bytes of each request.  If zero, 32.
+	Size int
+
+	// Method selects how requests are sent; the default is Auto.
+	Method Method
+
+	// OnReply, if not nil, is called after each echo request with its
+	// outcome, including lost requests.
+	OnReply func(*Reply)
+}
+
+// Reply is the outcome of one echo request.
+type Reply struct {
+	Seq int           // sequence number of the request, from 0
+	RTT time.Duration // round-trip time
+	TTL int           // TTL of the reply, or 0 if unknown
+	Err error         // nil if a reply was received
+}
+
+// Statistics summarizes a run of a Pinger.
+type Statistics struct {
+	Addr      *net.IPAddr // address pinged
+	Offloaded bool        // sent with the netdev's built-in ping
+	Sent      int         // echo requests sent
+	Received  int         // replies received
+	MinRTT    time.Duration
+	AvgRTT    time.Duration
+	MaxRTT    time.Duration
+	StdDevRTT time.Duration
+	MinTTL    int // 0 if unknown
+	MaxTTL    int // 0 if unknown
+}
+
+// Loss returns the percentage of echo requests without a reply.
+func (s *Statistics) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) / float64(s.Sent) * 100
+}
+
+// nextID is the ICMP echo identifier of the next Pinger run
+var nextID atomic.Uint32
+
+// The net functions used to resolve the address and send echo requests,
+// replaced by tests
+var (
+	resolveIPAddr = net.ResolveIPAddr
+	dialIP        = net.DialIP
+	pingOffload   = net.PingOffload
+)
+
+func init() {
+	nextID.Store(uint32(time.Now().UnixNano()))
+}
+
+// Run sends the echo requests and waits for their replies.  It returns
+// early, with the statistics so far, if ctx is done.  An error is returned
+// if the address can't be resolved or no method of sending pings is
+// available; lost requests are not errors.
+func (p *Pinger) Run(ctx context.Context) (*Statistics, error) {
+	raddr, err := resolveIPAddr("ip4", p.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	count, interval, timeout, size := p.Count, p.Interval, p.Timeout, p.Size
+	if count <= 0 {
+		count = defaultCount
+	}
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if size <= 0 {
+		size = defaultSize
+	}
+
+	var echo func(ctx context.Context, seq int) *Reply
+	var rawErr error
+	stats := &Statistics{Addr: raddr}
+
+	if p.Method == Auto || p.Method == Raw {
+		var c *net.IPConn
+		c, rawErr = dialIP("ip4:icmp", nil, raddr)
+		if rawErr == nil {
+			defer c.Close()
+			e := &rawEcho{c: c, id: uint16(nextID.Add(1)), timeout: timeout,
+				size: size, buf: make([]byte, 60+8+size)}
+			echo = e.echo
+		} else if p.Method == Raw {
+			return nil, rawErr
+		}
+	}
+	if echo == nil {
+		stats.Offloaded = true
+		echo = func(ctx context.Context, seq int) *Reply {
+			return offloadEcho(ctx, raddr.IP, seq, size, timeout)
+		}
+	}
+
+	var sum, sumSq float64
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			t := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return stats.finish(sum, sumSq), nil
+			case <-t.C:
+			}
+		} else if ctx.Err() != nil {
+			return stats.finish(sum, sumSq), nil
+		}
+
+		r := echo(ctx, seq)
+		if ctx.Err() != nil {
+			// The request was interrupted; it's neither sent nor lost
+			return stats.finish(sum, sumSq), nil
+		}
+		if stats.Offloaded && errors.Is(r.Err, errors.ErrUnsupported) {
+			// Neither raw sockets nor a built-in ping
+			if rawErr != nil {
+				return nil, rawErr
+			}
+			return nil, r.Err
+		}
+		stats.Sent++
+		if r.Err == nil {
+			stats.Received++
+			stats.add(r)
+			rtt := float64(r.RTT)
+			sum += rtt
+			sumSq += rtt * rtt
+		}
+		if p.OnReply != nil {
+			p.OnReply(r)
+		}
+	}
+	return stats.finish(sum, sumSq), nil
+}
+
+func (s *Statistics) add(r *Reply) {
+	if s.Received == 1 || r.RTT < s.MinRTT {
+		s.MinRTT = r.RTT
+	}
+	if r.RTT > s.MaxRTT {
+		s.MaxRTT = r.RTT
+	}
+	if r.TTL > 0 {
+		if s.MinTTL == 0 || r.TTL < s.MinTTL {
+			s.MinTTL = r.TTL
+		}
+		if r.TTL > s.MaxTTL {
+			s.MaxTTL = r.TTL
+		}
+	}
+}
+
+func (s *Statistics) finish(sum, sumSq float64) *Statistics {
+	if s.Received > 0 {
+		n := float64(s.Received)
+		avg := sum / n
+		s.AvgRTT = time.Duration(avg)
+		if v := sumSq/n - avg*avg; v > 0 {
+			s.StdDevRTT = time.Duration(math.Sqrt(v))
+		}
+	}
+	return s
+}
+
+// offloadEcho sends one echo request with the netdev's built-in ping.  The
+// wait for the reply is cut short by ctx: the timeout is capped by its
+// deadline, and it returns when ctx is done, leaving the netdev to time
+// out on its own.
+func offloadEcho(ctx context.Context, ip net.IP, seq, size int, timeout time.Duration) *Reply {
+	if d, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(d))
+	}
+	done := make(chan *Reply, 1)
+	go func() {
+		rtt, ttl, err := pingOffload(ip, size, timeout)
+		done <- &Reply{Seq: seq, RTT: rtt, TTL: ttl, Err: err}
+	}()
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		return &Reply{Seq: seq, Err: ctx.Err()}
+	}
+}
+
+const (
+	icmpEchoReply   = 0
+	icmpEchoRequest = 8
+)
+
+// rawEcho sends echo requests over a raw IP socket
+type rawEcho struct {
+	c       *net.IPConn
+	id      uint16
+	timeout time.Duration
+	size    int
+	buf     []byte
+}
+
+func (e *rawEcho) echo(ctx context.Context, seq int) *Reply {
+	r := &Reply{Seq: seq}
+	req := make([]byte, 8+e.size)
+	req[0] = icmpEchoRequest
+	req[4], req[5] = byte(e.id>>8), byte(e.id)
+	req[6], req[7] = byte(seq>>8), byte(seq)
+	for i := 8; i < len(req); i++ {
+		req[i] = byte(i)
+	}
+	cs := checksum(req)
+	req[2], req[3] = byte(cs>>8), byte(cs)
+
+	e.c.SetDeadline(time.Now().Add(e.timeout))
+	// Interrupt the wait for the reply when ctx is done
+	stop := context.AfterFunc(ctx, func() { e.c.SetReadDeadline(time.Now()) })
+	defer stop()
+	start := time.Now()
+	if _, r.Err = e.c.Write(req); r.Err != nil {
+		return r
+	}
+	for {
+		n, err := e.c.Read(e.buf)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		ttl, b := stripIPv4(e.buf[:n])
+		if len(b) < 8 || b[0] != icmpEchoReply {
+			continue
+		}
+		if uint16(b[4])<<8|uint16(b[5]) != e.id || int(uint16(b[6])<<8|uint16(b[7])) != seq&0xffff {
+			continue
+		}
+		r.RTT = time.Since(start)
+		r.TTL = ttl
+		return r
+	}
+}
+
+// stripIPv4 returns the TTL and payload of b if it starts with an IPv4
+// header, or 0 and b as-is if the netdev already stripped the header.
+func stripIPv4(b []byte) (int, []byte) {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return 0, b
+	}
+	l := int(b[0]&0x0f) << 2
+	if l < 20 || l > len(b) {
+		return 0, b
+	}
+	return int(b[8]), b[l:]
+}
+
+// checksum returns the Internet checksum (RFC 1071) of b
+func checksum(b []byte) uint16 {
+	var s uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		s += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		s += uint32(b[len(b)-1]) << 8
+	}
+	for s>>16 != 0 {
+		s = s&0xffff + s>>16
+	}
+	return ^uint16(s)
+}
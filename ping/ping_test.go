@@ -0,0 +1,145 @@
+// Pinger tests
+
+package ping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+var errNoRaw = errors.New("no raw sockets")
+
+// fakePing replaces resolveIPAddr with one parsing IP literals, dialIP with
+// one failing with errNoRaw, and pingOffload with one calling offload.
+func fakePing(t *testing.T, offload func(ip net.IP, size int, timeout time.Duration) (time.Duration, int, error)) {
+	oldResolveIPAddr, oldDialIP, oldPingOffload := resolveIPAddr, dialIP, pingOffload
+	resolveIPAddr = func(network, address string) (*net.IPAddr, error) {
+		return &net.IPAddr{IP: net.ParseIP(address).To4()}, nil
+	}
+	dialIP = func(network string, laddr, raddr *net.IPAddr) (*net.IPConn, error) {
+		return nil, errNoRaw
+	}
+	pingOffload = offload
+	t.Cleanup(func() {
+		resolveIPAddr, dialIP, pingOffload = oldResolveIPAddr, oldDialIP, oldPingOffload
+	})
+}
+
+func TestRunFallback(t *testing.T) {
+	calls := 0
+	fakePing(t, func(ip net.IP, size int, timeout time.Duration) (time.Duration, int, error) {
+		calls++
+		return time.Millisecond, 64, nil
+	})
+
+	// Auto falls back to the built-in ping
+	p := &Pinger{Addr: "192.0.2.1", Count: 2, Interval: time.Millisecond}
+	stats, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Offloaded || stats.Received != 2 || calls != 2 {
+		t.Errorf("Run = %+v after %d offloaded pings, want 2 offloaded replies", stats, calls)
+	}
+
+	// Raw doesn't
+	calls = 0
+	p.Method = Raw
+	if _, err := p.Run(context.Background()); err != errNoRaw || calls != 0 {
+		t.Errorf("Raw Run = %v after %d offloaded pings, want %v after 0", err, calls, errNoRaw)
+	}
+
+	// Without a built-in ping either, Auto reports why raw sockets failed
+	pingOffload = func(ip net.IP, size int, timeout time.Duration) (time.Duration, int, error) {
+		return 0, 0, errors.ErrUnsupported
+	}
+	p.Method = Auto
+	if _, err := p.Run(context.Background()); err != errNoRaw {
+		t.Errorf("Run without a built-in ping = %v, want %v", err, errNoRaw)
+	}
+}
+
+func TestRunStatistics(t *testing.T) {
+	replies := []struct {
+		rtt time.Duration
+		ttl int
+		err error
+	}{
+		{10 * time.Millisecond, 64, nil},
+		{0, 0, errors.New("timeout")},
+		{30 * time.Millisecond, 60, nil},
+		{20 * time.Millisecond, 0, nil},
+	}
+	seq := 0
+	fakePing(t, func(ip net.IP, size int, timeout time.Duration) (time.Duration, int, error) {
+		r := replies[seq]
+		seq++
+		return r.rtt, r.ttl, r.err
+	})
+
+	var lost []int
+	p := &Pinger{Addr: "192.0.2.1", Count: len(replies), Interval: time.Millisecond,
+		OnReply: func(r *Reply) {
+			if r.Err != nil {
+				lost = append(lost, r.Seq)
+			}
+		}}
+	stats, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 4 || stats.Received != 3 || stats.Loss() != 25 {
+		t.Errorf("sent %d, received %d, loss %v%%, want 4, 3, 25%%", stats.Sent, stats.Received, stats.Loss())
+	}
+	if len(lost) != 1 || lost[0] != 1 {
+		t.Errorf("lost requests %v, want [1]", lost)
+	}
+	if stats.MinRTT != 10*time.Millisecond || stats.AvgRTT != 20*time.Millisecond || stats.MaxRTT != 30*time.Millisecond {
+		t.Errorf("RTT min/avg/max %v/%v/%v, want 10ms/20ms/30ms", stats.MinRTT, stats.AvgRTT, stats.MaxRTT)
+	}
+	// Population standard deviation of 10, 20 and 30ms
+	if d := stats.StdDevRTT - 8164966*time.Nanosecond; d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("RTT stddev %v, want 8.164966ms", stats.StdDevRTT)
+	}
+	// Unknown TTLs are left out
+	if stats.MinTTL != 60 || stats.MaxTTL != 64 {
+		t.Errorf("TTL min/max %d/%d, want 60/64", stats.MinTTL, stats.MaxTTL)
+	}
+}
+
+func TestRunOffloadCancel(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	timeouts := make(chan time.Duration, 2)
+	fakePing(t, func(ip net.IP, size int, timeout time.Duration) (time.Duration, int, error) {
+		timeouts <- timeout
+		<-release
+		return 0, 0, errors.New("timeout")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	p := &Pinger{Addr: "192.0.2.1", Timeout: time.Hour}
+	start := time.Now()
+	stats, err := p.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Run returned %v after the context was done", d)
+	}
+	// The interrupted request isn't counted as lost
+	if stats.Sent != 0 {
+		t.Errorf("sent %d, want 0", stats.Sent)
+	}
+	// The timeout is capped by the context's deadline
+	if n := len(timeouts); n != 1 {
+		t.Fatalf("%d offloaded pings, want 1", n)
+	}
+	if timeout := <-timeouts; timeout > 50*time.Millisecond {
+		t.Errorf("offloaded ping timeout %v, want at most 50ms", timeout)
+	}
+}
@@ -0,0 +1,180 @@
+// In-memory Listener
+
+package net
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pipeListenerAddr is the address of a PipeListener
+type pipeListenerAddr string
+
+func (pipeListenerAddr) Network() string  { return "pipe" }
+func (a pipeListenerAddr) String() string { return string(a) }
+
+// pipeListeners numbers PipeListener addresses
+var pipeListeners atomic.Uint32
+
+// PipeListener is an in-memory [Listener].  Its Accept returns the server
+// end of connections created by its Dial and DialContext methods, without
+// any netdev socket.  It lets an http.Server and an http.Client talk to
+// each other in-process, e.g. in tests:
+//
+//	l := net.ListenPipe()
+//	go http.Serve(l, handler)
+//	client := &http.Client{Transport: &http.Transport{DialContext: l.DialContext}}
+//
+// Unlike [Pipe], the connections are buffered in both directions, so a
+// Write doesn't wait for the peer to Read until the buffer is full.
+type PipeListener struct {
+	addr  pipeListenerAddr
+	conns chan Conn
+
+	once sync.Once
+	done chan struct{}
+}
+
+// ListenPipe returns a new PipeListener.  Its address is a unique name of
+// network "pipe", usable as a host name in URLs.
+func ListenPipe() *PipeListener {
+	return &PipeListener{
+		addr:  pipeListenerAddr("pipe-" + strconv.Itoa(int(pipeListeners.Add(1)))),
+		conns: make(chan Conn, defaultBacklog),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept waits for and returns the next connection dialed to the listener.
+func (l *PipeListener) Accept() (Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, &OpError{Op: "accept", Net: "pipe", Source: nil, Addr: l.addr, Err: ErrClosed}
+	}
+}
+
+// Close closes the listener.  Blocked Accept, Dial and DialContext calls
+// are unblocked and return errors.  Connections dialed but not yet
+// accepted are closed.
+func (l *PipeListener) Close() error {
+	first := false
+	l.once.Do(func() {
+		first = true
+		close(l.done)
+	})
+	if !first {
+		return &OpError{Op: "close", Net: "pipe", Source: nil, Addr: l.addr, Err: ErrClosed}
+	}
+	for {
+		select {
+		case c := <-l.conns:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Addr returns the listener's network address.
+func (l *PipeListener) Addr() Addr {
+	return l.addr
+}
+
+// Dial connects to the listener, returning the client end of a new
+// connection whose server end is returned by Accept.
+func (l *PipeListener) Dial() (Conn, error) {
+	return l.DialContext(context.Background(), "pipe", string(l.addr))
+}
+
+// DialContext is like Dial, giving up when ctx is done before the
+// connection is queued for Accept.  The network and address are ignored;
+// DialContext has the signature of http.Transport.DialContext so it can be
+// plugged into an http.Client.
+func (l *PipeListener) DialContext(ctx context.Context, network, address string) (Conn, error) {
+	if isClosedChan(l.done) {
+		return nil, &OpError{Op: "dial", Net: "pipe", Source: nil, Addr: l.addr, Err: ErrClosed}
+	}
+	cep, sep := newMemPair(false)
+	client := &bufPipe{ep: cep, laddr: pipeAddr{}, raddr: l.addr}
+	server := &bufPipe{ep: sep, laddr: l.addr, raddr: pipeAddr{}}
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, &OpError{Op: "dial", Net: "pipe", Source: nil, Addr: l.addr, Err: ErrClosed}
+	case <-ctx.Done():
+		return nil, &OpError{Op: "dial", Net: "pipe", Source: nil, Addr: l.addr, Err: ctx.Err()}
+	}
+}
+
+// bufPipe is one end of a buffered in-memory connection
+type bufPipe struct {
+	ep    *memEndpoint
+	laddr Addr
+	raddr Addr
+}
+
+func (p *bufPipe) LocalAddr() Addr  { return p.laddr }
+func (p *bufPipe) RemoteAddr() Addr { return p.raddr }
+
+func (p *bufPipe) Read(b []byte) (int, error) {
+	n, _, err := p.ep.read(b)
+	if err != nil && err != io.EOF && err != io.ErrClosedPipe {
+		err = &OpError{Op: "read", Net: "pipe", Err: err}
+	}
+	return n, err
+}
+
+func (p *bufPipe) Write(b []byte) (int, error) {
+	n, err := p.ep.writeTo(p.ep.wq, b, nil)
+	if err != nil && err != io.ErrClosedPipe {
+		err = &OpError{Op: "write", Net: "pipe", Err: err}
+	}
+	return n, err
+}
+
+func (p *bufPipe) SetDeadline(t time.Time) error {
+	if p.ep.closed() {
+		return io.ErrClosedPipe
+	}
+	p.ep.readDeadline.set(t)
+	p.ep.writeDeadline.set(t)
+	return nil
+}
+
+func (p *bufPipe) SetReadDeadline(t time.Time) error {
+	if p.ep.closed() {
+		return io.ErrClosedPipe
+	}
+	p.ep.readDeadline.set(t)
+	return nil
+}
+
+func (p *bufPipe) SetWriteDeadline(t time.Time) error {
+	if p.ep.closed() {
+		return io.ErrClosedPipe
+	}
+	p.ep.writeDeadline.set(t)
+	return nil
+}
+
+// CloseWrite makes the peer's reads return io.EOF once it has read the
+// data written so far.
+func (p *bufPipe) CloseWrite() error {
+	if p.ep.closed() {
+		return io.ErrClosedPipe
+	}
+	p.ep.wq.closeWrite()
+	return nil
+}
+
+func (p *bufPipe) Close() error {
+	p.ep.close()
+	return nil
+}
@@ -0,0 +1,141 @@
+// In-memory Listener tests
+
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeListener(t *testing.T) {
+	l := ListenPipe()
+	defer l.Close()
+
+	if l.Addr().Network() != "pipe" || !strings.HasPrefix(l.Addr().String(), "pipe-") {
+		t.Errorf("Addr() = %s/%s, want pipe/pipe-N", l.Addr().Network(), l.Addr())
+	}
+	if other := ListenPipe(); other.Addr().String() == l.Addr().String() {
+		t.Errorf("two PipeListeners share the address %s", l.Addr())
+	}
+
+	c, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if c.RemoteAddr() != l.Addr() || s.LocalAddr() != l.Addr() {
+		t.Errorf("conn addresses %v, %v, want %v", c.RemoteAddr(), s.LocalAddr(), l.Addr())
+	}
+
+	// Writes are buffered, so they don't wait for the peer to read
+	if _, err := c.Write([]byte("request")); err != nil {
+		t.Fatal(err)
+	}
+	c.(interface{ CloseWrite() error }).CloseWrite()
+	b, err := io.ReadAll(s)
+	if err != nil || string(b) != "request" {
+		t.Fatalf("server read %q, %v, want %q, nil", b, err, "request")
+	}
+	if _, err := s.Write([]byte("response")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	b, err = io.ReadAll(c)
+	if err != nil || string(b) != "response" {
+		t.Errorf("client read %q, %v, want %q, nil", b, err, "response")
+	}
+}
+
+func TestPipeListenerDeadline(t *testing.T) {
+	l := ListenPipe()
+	defer l.Close()
+
+	c, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := c.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	c.Close()
+	if err := c.SetDeadline(time.Now()); err != io.ErrClosedPipe {
+		t.Errorf("SetDeadline after Close = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestPipeListenerClose(t *testing.T) {
+	l := ListenPipe()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Accept = %v, want %v", err, ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't wake Accept")
+	}
+	if _, err := l.Dial(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Dial after Close = %v, want %v", err, ErrClosed)
+	}
+	if err := l.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestPipeListenerCloseClosesQueued(t *testing.T) {
+	l := ListenPipe()
+
+	c, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	l.Close()
+
+	// The server end was closed with the listener
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read from unaccepted conn = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestPipeListenerDialContext(t *testing.T) {
+	l := ListenPipe()
+	defer l.Close()
+
+	// Fill the backlog, then time out
+	for i := 0; i < defaultBacklog; i++ {
+		c, err := l.Dial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.DialContext(ctx, "pipe", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DialContext with full backlog = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,273 @@
+// Socket readiness polling
+
+package net
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PollEvent is a set of socket readiness events, as in poll(2).
+type PollEvent int
+
+const (
+	PollRead   PollEvent = _POLLIN  // data to read, or a connection to accept
+	PollWrite  PollEvent = _POLLOUT // room to write
+	PollError  PollEvent = _POLLERR // socket error; reported even if not asked for
+	PollHangup PollEvent = _POLLHUP // peer closed; reported even if not asked for
+)
+
+// Pollable is a socket a Poller can wait on: a *TCPConn, *UDPConn,
+// *IPConn, *TLSConn, *TCPListener or *TLSListener.  A TLSConn running TLS
+// in software can't be polled, as its TCP socket being readable doesn't
+// mean a TLS record is.
+type Pollable interface {
+	pollSocket() *socket
+}
+
+func (c *TCPConn) pollSocket() *socket     { return c.sock }
+func (c *UDPConn) pollSocket() *socket     { return c.sock }
+func (c *IPConn) pollSocket() *socket      { return c.sock }
+func (c *TLSConn) pollSocket() *socket     { return c.sock }
+func (l *TCPListener) pollSocket() *socket { return l.sock }
+func (l *TLSListener) pollSocket() *socket { return l.l.sock }
+
+// PollResult reports the events ready on a socket.
+type PollResult struct {
+	Conn   Pollable
+	Events PollEvent
+}
+
+// A Poller waits for readiness events on many sockets at once, letting a
+// single goroutine serve many connections instead of one goroutine (and
+// stack) per blocked Read or Accept.  It requires netdev support for
+// polling.
+//
+// The zero Poller is empty and ready to use.  Add and Remove may be called
+// concurrently with Wait, which picks up the change within 50ms; Wait
+// itself must not be called concurrently.
+type Poller struct {
+	mu      sync.Mutex
+	entries []pollEntry
+	wake    chan struct{} // signaled by Add and Remove
+
+	// reused by Wait
+	conns   []Pollable
+	fds     []int
+	events  []int
+	revents []int
+}
+
+type pollEntry struct {
+	conn   Pollable
+	events PollEvent
+}
+
+// pollerWakeInterval is how long Wait polls the netdev at a time before
+// picking up the sockets added or removed meanwhile.
+const pollerWakeInterval = 50 * time.Millisecond
+
+// Add adds c to the sockets waited on for events.  If c is already added,
+// its events are replaced.
+func (p *Poller) Add(c Pollable, events PollEvent) error {
+	if c.pollSocket() == nil || c.pollSocket().closed.Load() {
+		return ErrClosed
+	}
+	if tc, ok := c.(*TLSConn); ok && tc.soft != nil {
+		return &OpError{Op: "poll", Net: tc.net, Source: tc.laddr, Addr: tc.raddr, Err: errors.ErrUnsupported}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.wakeLocked()
+	for i := range p.entries {
+		if p.entries[i].conn == c {
+			p.entries[i].events = events
+			return nil
+		}
+	}
+	p.entries = append(p.entries, pollEntry{c, events})
+	return nil
+}
+
+// Remove removes c from the sockets waited on.  Closed sockets are
+// removed automatically by Wait.
+func (p *Poller) Remove(c Pollable) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.entries {
+		if p.entries[i].conn == c {
+			p.entries = append(p.entries[:i], p.entries[i+1:]...)
+			p.wakeLocked()
+			return
+		}
+	}
+}
+
+// wakeLocked signals a Wait in progress that the sockets changed.
+func (p *Poller) wakeLocked() {
+	if p.wake == nil {
+		p.wake = make(chan struct{}, 1)
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of sockets waited on.
+func (p *Poller) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Wait blocks until at least one socket is ready, or until deadline.  A zero
+// deadline means no deadline.  The ready sockets are appended to ready and
+// the result is returned; on deadline, ready is returned unchanged with a
+// nil error.
+func (p *Poller) Wait(deadline time.Time, ready []PollResult) ([]PollResult, error) {
+	np, ok := netdev.(netdevPoller)
+	if !ok {
+		return ready, &OpError{Op: "poll", Err: errors.ErrUnsupported}
+	}
+	defer func() {
+		for i := range p.conns {
+			p.conns[i] = nil
+		}
+	}()
+
+	for {
+		wake := p.load()
+		if len(p.fds) == 0 {
+			// Nothing to poll; wait for Add
+			if !sleepUntil(deadline, wake) {
+				return ready, nil
+			}
+			continue
+		}
+
+		d := time.Now().Add(pollerWakeInterval)
+		if !deadline.IsZero() && deadline.Before(d) {
+			d = deadline
+		}
+		n, err := np.Poll(p.fds, p.events, p.revents, d)
+		if err != nil {
+			return ready, &OpError{Op: "poll", Err: err}
+		}
+		if n > 0 {
+			for i := 0; i < len(p.revents) && n > 0; i++ {
+				if p.revents[i] != 0 {
+					ready = append(ready, PollResult{p.conns[i], PollEvent(p.revents[i])})
+					n--
+				}
+			}
+			return ready, nil
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return ready, nil
+		}
+	}
+}
+
+// load drops the closed sockets and loads the others into conns, fds,
+// events and zeroed revents for polling.  It returns the channel signaled
+// by the next Add or Remove.
+func (p *Poller) load() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.wake == nil {
+		p.wake = make(chan struct{}, 1)
+	}
+	select {
+	case <-p.wake:
+	default:
+	}
+
+	p.conns, p.fds, p.events = p.conns[:0], p.fds[:0], p.events[:0]
+	live := p.entries[:0]
+	for _, e := range p.entries {
+		s := e.conn.pollSocket()
+		if s.closed.Load() {
+			continue
+		}
+		live = append(live, e)
+		p.conns = append(p.conns, e.conn)
+		p.fds = append(p.fds, s.fd)
+		p.events = append(p.events, int(e.events))
+	}
+	for i := len(live); i < len(p.entries); i++ {
+		p.entries[i] = pollEntry{}
+	}
+	p.entries = live
+
+	if cap(p.revents) < len(p.fds) {
+		p.revents = make([]int, len(p.fds))
+	}
+	p.revents = p.revents[:len(p.fds)]
+	for i := range p.revents {
+		p.revents[i] = 0
+	}
+	return p.wake
+}
+
+// sleepUntil waits for wake to be signaled, reporting true, or for
+// deadline to pass, reporting false.  A zero deadline means no deadline.
+func sleepUntil(deadline time.Time, wake <-chan struct{}) bool {
+	if deadline.IsZero() {
+		<-wake
+		return true
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-wake:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// pollIO is set by SetPollIO
+var pollIO atomic.Bool
+
+// SetPollIO enables or disables waiting for readiness with netdev polling
+// before blocking reads and accepts.  With polling, Read and Accept block in
+// the netdev's Poll rather than in Recv or Accept, and TCPListener accept
+// deadlines need no helper goroutine.  SetPollIO has no effect if the netdev
+// doesn't support polling.  Polling is off by default.
+func SetPollIO(enabled bool) {
+	pollIO.Store(enabled)
+}
+
+// waitReadable waits for fd to be readable if SetPollIO is enabled and the
+// netdev supports polling.  It returns os.ErrDeadlineExceeded if deadline
+// passes first; a poll returning nothing before then, spuriously or
+// interrupted, is retried.  Socket errors are left for the following read
+// to report.
+func waitReadable(fd int, deadline time.Time) error {
+	if !pollIO.Load() {
+		return nil
+	}
+	np, ok := netdevExt[netdevPoller](fd)
+	if !ok {
+		return nil
+	}
+	fds := [1]int{fd}
+	events := [1]int{_POLLIN}
+	var revents [1]int
+	for {
+		n, err := np.Poll(fds[:], events[:], revents[:], deadline)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return os.ErrDeadlineExceeded
+		}
+	}
+}
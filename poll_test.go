@@ -0,0 +1,52 @@
+// Netdev readiness polling tests
+
+package net
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// pollNetdev is a netdev whose Poll reports nothing ready for the first
+// empty polls, then fd readable.
+type pollNetdev struct {
+	nopNetdev
+	empty int // polls left reporting nothing
+	polls int
+}
+
+func (d *pollNetdev) Poll(fds []int, events []int, revents []int, deadline time.Time) (int, error) {
+	d.polls++
+	if d.empty > 0 {
+		d.empty--
+		return 0, nil
+	}
+	revents[0] = _POLLIN
+	return 1, nil
+}
+
+func TestWaitReadable(t *testing.T) {
+	dev := &pollNetdev{}
+	oldNetdev := netdev
+	netdev = dev
+	SetPollIO(true)
+	t.Cleanup(func() {
+		netdev = oldNetdev
+		SetPollIO(false)
+	})
+
+	// Empty polls before the deadline, or without one, are retried
+	for _, deadline := range []time.Time{{}, time.Now().Add(time.Hour)} {
+		dev.empty, dev.polls = 2, 0
+		if err := waitReadable(7, deadline); err != nil || dev.polls != 3 {
+			t.Errorf("waitReadable(%v) = %v after %d polls, want nil after 3", deadline, err, dev.polls)
+		}
+	}
+
+	dev.empty, dev.polls = 2, 0
+	if err := waitReadable(7, time.Now().Add(-time.Second)); !errors.Is(err, os.ErrDeadlineExceeded) || dev.polls != 1 {
+		t.Errorf("waitReadable past the deadline = %v after %d polls, want %v after 1", err, dev.polls, os.ErrDeadlineExceeded)
+	}
+}
@@ -0,0 +1,388 @@
+// Package ratelimit provides token-bucket bandwidth shaping for net.Conn
+// and net.Listener.
+//
+// Each connection gets its own read and write Limiter, and may share
+// Limiters with other connections to cap their aggregate rate, e.g. to keep
+// firmware downloads from starving telemetry on a slow uplink:
+//
+//	uplink := ratelimit.NewLimiter(64<<10, 0)      // 64 KB/s for all conns
+//	lim := ratelimit.Limits{WriteRate: 16 << 10, Write: uplink}
+//	srv := &http.Server{WrapConn: ratelimit.Wrapper(lim)}
+//	client := &http.Client{Transport: &http.Transport{
+//		DialContext: ratelimit.DialContext(nil, lim),
+//	}}
+//
+// Rates can be changed at runtime with Limiter.SetRate.  Waiting for
+// tokens respects the read and write deadlines of the connection, and is
+// interrupted by Close.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultBurst is the bucket size of Limiters created with a zero burst.
+const DefaultBurst = 4096
+
+// A Limiter is a token bucket limiting a byte rate.  The bucket fills at
+// rate bytes per second, up to burst bytes.  A Limiter with a rate of zero
+// or less doesn't limit.
+//
+// A Limiter may be shared by many connections, and its methods may be
+// called concurrently.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter allowing rate bytes per second with bursts of
+// up to burst bytes.  If burst is zero or less, DefaultBurst is used.  The
+// bucket starts full.
+func NewLimiter(rate, burst int) *Limiter {
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	return &Limiter{
+		rate:   float64(rate),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Rate returns the rate of the Limiter, in bytes per second.
+func (l *Limiter) Rate() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.rate)
+}
+
+// SetRate changes the rate of the Limiter, in bytes per second.  A rate of
+// zero or less removes the limit.  Waits already in progress are not
+// shortened.
+func (l *Limiter) SetRate(rate int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(time.Now())
+	l.rate = float64(rate)
+}
+
+// Burst returns the bucket size of the Limiter, in bytes.
+func (l *Limiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// SetBurst changes the bucket size of the Limiter, in bytes.  If burst is
+// zero or less, DefaultBurst is used.
+func (l *Limiter) SetBurst(burst int) {
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(time.Now())
+	l.burst = burst
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+}
+
+// advance fills the bucket for the time elapsed since the last call.
+// l.mu must be held.
+func (l *Limiter) advance(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+}
+
+// reserve takes n tokens, going into debt if the bucket doesn't hold
+// enough, and returns how long to wait for the debt to be repaid.
+func (l *Limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	l.advance(time.Now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel gives back n tokens taken by reserve
+func (l *Limiter) cancel(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return
+	}
+	l.tokens += float64(n)
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+}
+
+// wait takes n tokens and blocks until the bucket is out of debt, the
+// deadline passes or done is closed.  If the wait would go past the
+// deadline, wait gives the tokens back and fails right away.
+func (l *Limiter) wait(n int, deadline time.Time, done <-chan struct{}) error {
+	d := l.reserve(n)
+	if d <= 0 {
+		return nil
+	}
+	if !deadline.IsZero() && time.Until(deadline) < d {
+		l.cancel(n)
+		return os.ErrDeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-done:
+		l.cancel(n)
+		return net.ErrClosed
+	}
+}
+
+// Limits configure the shaping of a connection.
+type Limits struct {
+	// ReadRate and WriteRate are the per-connection rates, in bytes per
+	// second.  Zero means no per-connection limit.
+	ReadRate, WriteRate int
+
+	// Burst is the bucket size of the per-connection Limiters.  If
+	// zero, DefaultBurst is used.
+	Burst int
+
+	// Read and Write are optional Limiters shared with other
+	// connections, capping their aggregate rate.
+	Read, Write *Limiter
+}
+
+// Conn is a net.Conn shaping the traffic of the net.Conn it wraps.
+type Conn struct {
+	net.Conn
+
+	readLimiter  *Limiter
+	writeLimiter *Limiter
+	sharedRead   *Limiter
+	sharedWrite  *Limiter
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewConn returns c wrapped in a Conn shaping its traffic with lim.
+func NewConn(c net.Conn, lim Limits) *Conn {
+	return &Conn{
+		Conn:         c,
+		readLimiter:  NewLimiter(lim.ReadRate, lim.Burst),
+		writeLimiter: NewLimiter(lim.WriteRate, lim.Burst),
+		sharedRead:   lim.Read,
+		sharedWrite:  lim.Write,
+		done:         make(chan struct{}),
+	}
+}
+
+// ReadLimiter returns the per-connection read Limiter, to adjust its rate
+// at runtime.
+func (c *Conn) ReadLimiter() *Limiter { return c.readLimiter }
+
+// WriteLimiter returns the per-connection write Limiter, to adjust its rate
+// at runtime.
+func (c *Conn) WriteLimiter() *Limiter { return c.writeLimiter }
+
+// Unwrap returns the wrapped connection.
+func (c *Conn) Unwrap() net.Conn { return c.Conn }
+
+// chunk returns the largest chunk of n bytes fitting in the buckets
+func chunk(n int, own, shared *Limiter) int {
+	if b := own.Burst(); n > b {
+		n = b
+	}
+	if shared != nil {
+		if b := shared.Burst(); n > b {
+			n = b
+		}
+	}
+	return n
+}
+
+func (c *Conn) opError(op string, err error) error {
+	// Wrapped Conns, such as pipes or unconnected sockets, may have no
+	// addresses
+	laddr, raddr := c.LocalAddr(), c.RemoteAddr()
+	var network string
+	if laddr != nil {
+		network = laddr.Network()
+	} else if raddr != nil {
+		network = raddr.Network()
+	}
+	return &net.OpError{Op: op, Net: network, Source: laddr, Addr: raddr, Err: err}
+}
+
+// Read reads from the wrapped connection once the read Limiters allow it.
+// At most one bucket's worth of bytes is read at a time.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	deadline := c.readDeadline
+	c.mu.Unlock()
+
+	// Wait for any debt from earlier reads to be repaid, then charge
+	// what was actually read.
+	if err := c.readLimiter.wait(0, deadline, c.done); err != nil {
+		return 0, c.opError("read", err)
+	}
+	if c.sharedRead != nil {
+		if err := c.sharedRead.wait(0, deadline, c.done); err != nil {
+			return 0, c.opError("read", err)
+		}
+	}
+	n, err := c.Conn.Read(b[:chunk(len(b), c.readLimiter, c.sharedRead)])
+	if n > 0 {
+		c.readLimiter.reserve(n)
+		if c.sharedRead != nil {
+			c.sharedRead.reserve(n)
+		}
+	}
+	return n, err
+}
+
+// Write writes to the wrapped connection as fast as the write Limiters
+// allow, one bucket's worth of bytes at a time.
+func (c *Conn) Write(b []byte) (n int, err error) {
+	c.mu.Lock()
+	deadline := c.writeDeadline
+	c.mu.Unlock()
+
+	for len(b) > 0 {
+		m := chunk(len(b), c.writeLimiter, c.sharedWrite)
+		if err := c.writeLimiter.wait(m, deadline, c.done); err != nil {
+			return n, c.opError("write", err)
+		}
+		if c.sharedWrite != nil {
+			if err := c.sharedWrite.wait(m, deadline, c.done); err != nil {
+				// Nothing is written, so give the tokens back
+				c.writeLimiter.cancel(m)
+				return n, c.opError("write", err)
+			}
+		}
+		nn, err := c.Conn.Write(b[:m])
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		b = b[m:]
+	}
+	return n, nil
+}
+
+// Close closes the wrapped connection, interrupting any Read or Write
+// waiting on the Limiters.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+// SetDeadline sets the read and write deadlines of the connection, which
+// also bound the waits on the Limiters.
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the connection, which also
+// bounds the waits on the read Limiters.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the connection, which also
+// bounds the waits on the write Limiters.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// CloseWrite shuts down the writing side of the wrapped connection, if it
+// supports half-close.
+func (c *Conn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.opError("close", errors.ErrUnsupported)
+}
+
+// Wrapper returns a function wrapping connections in a Conn with lim,
+// suitable for http.Server.WrapConn.
+func Wrapper(lim Limits) func(net.Conn) net.Conn {
+	return func(c net.Conn) net.Conn {
+		return NewConn(c, lim)
+	}
+}
+
+// DialContext returns a dial function that dials with dial and wraps the
+// connections in a Conn with lim, suitable for http.Transport.DialContext.
+// If dial is nil, a zero net.Dialer is used.
+func DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error),
+	lim Limits) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return NewConn(c, lim), nil
+	}
+}
+
+type listener struct {
+	net.Listener
+	lim Limits
+}
+
+// NewListener returns l wrapped in a net.Listener which wraps the accepted
+// connections in a Conn with lim.  Each connection gets its own
+// per-connection Limiters; lim.Read and lim.Write are shared by all.
+func NewListener(l net.Listener, lim Limits) net.Listener {
+	return &listener{Listener: l, lim: lim}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(c, l.lim), nil
+}
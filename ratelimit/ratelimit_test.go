@@ -0,0 +1,203 @@
+// Token bucket and Conn shaping tests
+
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+var reserveTests = []struct {
+	rate, burst int
+	n           []int         // successive reservations
+	wait        time.Duration // wait expected for the last one
+}{
+	{1000, 100, []int{100}, 0},
+	{1000, 100, []int{100, 50}, 50 * time.Millisecond},
+	{1000, 100, []int{60, 60}, 20 * time.Millisecond},
+	{1000, 0, []int{DefaultBurst}, 0},
+	{0, 100, []int{100, 1000}, 0},
+	{-1, 100, []int{100, 1000}, 0},
+}
+
+func TestLimiterReserve(t *testing.T) {
+	for i, tt := range reserveTests {
+		l := NewLimiter(tt.rate, tt.burst)
+		var d time.Duration
+		for _, n := range tt.n {
+			d = l.reserve(n)
+		}
+		// The bucket refills a little between the calls
+		if d > tt.wait || d < tt.wait-5*time.Millisecond {
+			t.Errorf("#%d: NewLimiter(%d, %d) reserve%v = %v, want %v", i, tt.rate, tt.burst, tt.n, d, tt.wait)
+		}
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := NewLimiter(1000, 100)
+	l.reserve(150)
+	l.cancel(150)
+	if d := l.reserve(100); d > 0 {
+		t.Errorf("reserve after cancel = %v, want 0", d)
+	}
+	l.cancel(1000)
+	if d := l.reserve(101); d <= 0 {
+		t.Errorf("cancel overfilled the bucket past its burst")
+	}
+}
+
+func TestLimiterSetRate(t *testing.T) {
+	l := NewLimiter(0, 100)
+	if d := l.reserve(1000); d != 0 {
+		t.Fatalf("unlimited reserve = %v, want 0", d)
+	}
+	l.SetRate(1000)
+	if r := l.Rate(); r != 1000 {
+		t.Errorf("Rate() = %d, want 1000", r)
+	}
+	l.reserve(100)
+	if d := l.reserve(100); d <= 50*time.Millisecond {
+		t.Errorf("reserve after SetRate = %v, want about 100ms", d)
+	}
+	l.SetBurst(10)
+	if b := l.Burst(); b != 10 {
+		t.Errorf("Burst() = %d, want 10", b)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := NewLimiter(1000, 100)
+	l.reserve(100)
+
+	// A wait going past the deadline fails right away, without taking
+	// tokens
+	start := time.Now()
+	err := l.wait(100, time.Now().Add(10*time.Millisecond), nil)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("wait past deadline = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Errorf("wait past deadline blocked")
+	}
+	if d := l.reserve(0); d > 0 {
+		t.Errorf("failed wait kept its tokens")
+	}
+
+	done := make(chan struct{})
+	close(done)
+	if err := l.wait(1000, time.Time{}, done); err != net.ErrClosed {
+		t.Fatalf("wait after done = %v, want %v", err, net.ErrClosed)
+	}
+
+	l = NewLimiter(1000, 100)
+	start = time.Now()
+	if err := l.wait(120, time.Time{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Errorf("wait for 20 tokens at 1000/s took %v", d)
+	}
+}
+
+func TestConnWrite(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	go io.Copy(io.Discard, c2)
+
+	c := NewConn(c1, Limits{WriteRate: 1000, Burst: 100})
+	defer c.Close()
+	start := time.Now()
+	n, err := c.Write(make([]byte, 300))
+	if n != 300 || err != nil {
+		t.Fatalf("Write = %d, %v, want 300, nil", n, err)
+	}
+	// The first 100 bytes are the burst
+	if d := time.Since(start); d < 190*time.Millisecond {
+		t.Errorf("Write of 300 bytes at 1000/s took %v, want 200ms", d)
+	}
+}
+
+func TestConnWriteSharedDeadline(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	go io.Copy(io.Discard, c2)
+
+	shared := NewLimiter(10, 100)
+	shared.reserve(100)
+	c := NewConn(c1, Limits{WriteRate: 1000, Burst: 100, Write: shared})
+	defer c.Close()
+	c.SetWriteDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := c.Write(make([]byte, 100)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Write = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	// The per-connection tokens taken are given back
+	if d := c.WriteLimiter().reserve(100); d > 0 {
+		t.Errorf("failed Write kept %v of per-connection tokens", d)
+	}
+}
+
+func TestConnCloseInterruptsWait(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	c := NewConn(c1, Limits{WriteRate: 10, Burst: 10})
+	c.WriteLimiter().reserve(10)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Write(make([]byte, 10))
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Write = %v, want %v", err, net.ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't interrupt Write")
+	}
+}
+
+func TestWrapperUnwrap(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	c := Wrapper(Limits{})(c1)
+	u, ok := c.(interface{ Unwrap() net.Conn })
+	if !ok || u.Unwrap() != c1 {
+		t.Errorf("Wrapper conn doesn't unwrap to the wrapped conn")
+	}
+}
+
+// noAddrConn is a Conn without addresses
+type noAddrConn struct {
+	net.Conn
+}
+
+func (noAddrConn) LocalAddr() net.Addr  { return nil }
+func (noAddrConn) RemoteAddr() net.Addr { return nil }
+
+func TestConnErrorNoAddr(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	c := NewConn(noAddrConn{c1}, Limits{WriteRate: 10, Burst: 10})
+	defer c.Close()
+	c.WriteLimiter().reserve(10)
+	c.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := c.Write(make([]byte, 10))
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Write = %v, want an OpError for %v", err, os.ErrDeadlineExceeded)
+	}
+	if opErr.Net != "" || opErr.Source != nil || opErr.Addr != nil {
+		t.Errorf("OpError for %s from %v to %v, want no network or addresses", opErr.Net, opErr.Source, opErr.Addr)
+	}
+}
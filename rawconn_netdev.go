@@ -0,0 +1,212 @@
+// Raw netdev socket access
+
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// rawConn implements syscall.RawConn over a netdev socket.  The fd passed
+// to the callbacks is the netdev socket fd, for use with driver-specific
+// extensions of the netdev.
+type rawConn struct {
+	fd    int
+	net   string
+	laddr Addr
+	raddr Addr
+
+	// sock and the deadlines are nil for the rawConn passed to a Dialer
+	// or ListenConfig Control function, before the conn exists.
+	sock          *socket
+	readDeadline  *time.Time
+	writeDeadline *time.Time
+}
+
+// rawRetryInterval is how often Read and Write retry their callback when
+// the netdev can't poll for readiness.
+const rawRetryInterval = 10 * time.Millisecond
+
+func (c *rawConn) ok() bool {
+	return c.sock == nil || !c.sock.closed.Load()
+}
+
+// Control invokes f on the underlying netdev socket fd.
+func (c *rawConn) Control(f func(uintptr)) error {
+	if !c.ok() {
+		return &OpError{Op: "raw-control", Net: c.net, Source: nil, Addr: c.laddr, Err: ErrClosed}
+	}
+	f(uintptr(c.fd))
+	return nil
+}
+
+// Read invokes f on the underlying netdev socket fd until f returns true,
+// waiting for the socket to be readable in between, up to the read
+// deadline of the conn.
+func (c *rawConn) Read(f func(uintptr) bool) error {
+	var deadline time.Time
+	if c.readDeadline != nil {
+		deadline = *c.readDeadline
+	}
+	if err := c.wait(f, _POLLIN, deadline); err != nil {
+		return &OpError{Op: "raw-read", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return nil
+}
+
+// Write invokes f on the underlying netdev socket fd until f returns true,
+// waiting for the socket to be writable in between, up to the write
+// deadline of the conn.
+func (c *rawConn) Write(f func(uintptr) bool) error {
+	var deadline time.Time
+	if c.writeDeadline != nil {
+		deadline = *c.writeDeadline
+	}
+	if err := c.wait(f, _POLLOUT, deadline); err != nil {
+		return &OpError{Op: "raw-write", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return nil
+}
+
+// wait calls f until it returns true.  In between, it polls the socket for
+// events if the netdev supports polling, or else sleeps a little.  If the
+// poll reports a socket error or hangup f didn't handle, wait fails rather
+// than poll again, as the poll would return right away.
+func (c *rawConn) wait(f func(uintptr) bool, events int, deadline time.Time) error {
+	np, canPoll := netdevExt[netdevPoller](c.fd)
+	var revents [1]int
+	for {
+		if !c.ok() {
+			return ErrClosed
+		}
+		if f(uintptr(c.fd)) {
+			return nil
+		}
+		if revents[0]&(_POLLERR|_POLLHUP) != 0 {
+			return pollError(revents[0], events)
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return os.ErrDeadlineExceeded
+		}
+		if canPoll {
+			fds := [1]int{c.fd}
+			evs := [1]int{events}
+			revents[0] = 0
+			if _, err := np.Poll(fds[:], evs[:], revents[:], deadline); err != nil {
+				return err
+			}
+			continue
+		}
+		d := rawRetryInterval
+		if !deadline.IsZero() {
+			if left := time.Until(deadline); left < d {
+				d = left
+			}
+		}
+		time.Sleep(d)
+	}
+}
+
+// rawListener is the RawConn of a TCPListener, which only supports Control.
+type rawListener struct {
+	rawConn
+}
+
+func (l *rawListener) Read(func(uintptr) bool) error {
+	return &OpError{Op: "raw-read", Net: l.net, Source: nil, Addr: l.laddr, Err: errors.ErrUnsupported}
+}
+
+func (l *rawListener) Write(func(uintptr) bool) error {
+	return &OpError{Op: "raw-write", Net: l.net, Source: nil, Addr: l.laddr, Err: errors.ErrUnsupported}
+}
+
+// controlFunc runs a Dialer or ListenConfig Control function on a new
+// netdev socket, before it is connected or bound to address.
+type controlFunc func(fd int, address string) error
+
+// runControl runs ctrl, if any, on fd.  On error, the caller closes fd.
+func runControl(ctrl controlFunc, fd int, address string) error {
+	if ctrl == nil {
+		return nil
+	}
+	return ctrl(fd, address)
+}
+
+// controlNetwork returns the network name passed to Control functions,
+// which for TCP and UDP always includes the IP version.
+func controlNetwork(network string) string {
+	switch network {
+	case "tcp":
+		return "tcp4"
+	case "udp":
+		return "udp4"
+	}
+	return network
+}
+
+// control returns the controlFunc for d's Interface and Control or
+// ControlContext, if any.
+func (d *Dialer) control(ctx context.Context, network string) controlFunc {
+	network = controlNetwork(network)
+	var ctrl controlFunc
+	switch {
+	case d.ControlContext != nil:
+		ctrl = func(fd int, address string) error {
+			return d.ControlContext(ctx, network, address, &rawConn{fd: fd, net: network})
+		}
+	case d.Control != nil:
+		ctrl = func(fd int, address string) error {
+			return d.Control(network, address, &rawConn{fd: fd, net: network})
+		}
+	}
+	return bindToInterface(d.Interface, ctrl)
+}
+
+// control returns the controlFunc for lc's Interface and Control, if any.
+func (lc *ListenConfig) control(network string) controlFunc {
+	var ctrl controlFunc
+	if lc.Control != nil {
+		network = controlNetwork(network)
+		ctrl = func(fd int, address string) error {
+			return lc.Control(network, address, &rawConn{fd: fd, net: network})
+		}
+	}
+	return bindToInterface(lc.Interface, ctrl)
+}
+
+// bindToInterface returns ctrl preceded by binding the socket to the
+// interface named name, if name is not empty.
+func bindToInterface(name string, ctrl controlFunc) controlFunc {
+	if name == "" {
+		return ctrl
+	}
+	return func(fd int, address string) error {
+		if err := netdev.SetSockOpt(fd, _SOL_SOCKET, _SO_BINDTODEVICE, name); err != nil {
+			return err
+		}
+		if ctrl == nil {
+			return nil
+		}
+		return ctrl(fd, address)
+	}
+}
+
+var _ syscall.RawConn = (*rawConn)(nil)
+
+// pollError returns the error for the _POLLERR or _POLLHUP revents of a
+// poll for events: the connection was reset, or the peer hung up, which
+// ends reads and breaks writes.
+func pollError(revents, events int) error {
+	switch {
+	case revents&_POLLERR != 0:
+		return syscall.ECONNRESET
+	case events&_POLLIN != 0:
+		return io.EOF
+	default:
+		return syscall.EPIPE
+	}
+}
@@ -0,0 +1,194 @@
+// Open socket registry
+
+package net
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// socket tracks a netdev socket from the time the net package obtains its
+// fd from netdev.Socket or netdev.Accept until the fd is closed.  The
+// registry of open sockets is used to find leaked sockets; see OpenSockets.
+type socket struct {
+	fd      int
+	owner   string // "TCPConn", "TCPListener", "UDPConn", "IPConn", "TLSConn" or "TLSListener"
+	net     string
+	laddr   Addr // guarded by socketsMu; see setLocalAddr
+	raddr   Addr
+	created time.Time
+	stack   []uintptr
+	trace   *SocketTrace
+
+	stats     connStats
+	readFirst atomic.Bool
+	closed    atomic.Bool
+}
+
+var (
+	socketsMu sync.Mutex
+	sockets   = make(map[*socket]struct{})
+
+	// socketStacks enables recording of the creation stack of sockets
+	socketStacks atomic.Bool
+)
+
+// maxSocketStack is the max number of stack frames recorded per socket
+const maxSocketStack = 16
+
+// newSocket registers the fd as an open socket owned by owner.  The trace,
+// if any, stays with the socket until it is closed.
+func newSocket(fd int, owner, network string, laddr, raddr Addr, trace *SocketTrace) *socket {
+	s := &socket{
+		fd:      fd,
+		owner:   owner,
+		net:     network,
+		laddr:   laddr,
+		raddr:   raddr,
+		created: time.Now(),
+		trace:   trace,
+	}
+	if socketStacks.Load() {
+		pcs := make([]uintptr, maxSocketStack)
+		// Skip runtime.Callers, newSocket, and the net package caller
+		n := runtime.Callers(3, pcs)
+		s.stack = pcs[:n]
+	}
+	socketsMu.Lock()
+	sockets[s] = struct{}{}
+	socketsMu.Unlock()
+	netStats.socketsOpened.Add(1)
+	return s
+}
+
+// setLocalAddr sets the local address of a socket registered before it
+// was known, e.g. before connecting.
+func (s *socket) setLocalAddr(laddr Addr) {
+	socketsMu.Lock()
+	s.laddr = laddr
+	socketsMu.Unlock()
+}
+
+// close removes the socket from the registry and closes the fd on the
+// netdev.  Only the first call closes the fd; later calls return ErrClosed.
+func (s *socket) close() error {
+	if s == nil {
+		return ErrClosed
+	}
+	if s.closed.Swap(true) {
+		return ErrClosed
+	}
+	socketsMu.Lock()
+	delete(sockets, s)
+	socketsMu.Unlock()
+	netStats.socketsClosed.Add(1)
+	err := netdev.Close(s.fd)
+	s.trace.close(s.fd, err)
+	return err
+}
+
+func (s *socket) countSend(n int, err error) {
+	if s != nil {
+		s.stats.countSend(n, err)
+	}
+}
+
+func (s *socket) countRecv(n int, err error) {
+	if s != nil {
+		s.stats.countRecv(n, err)
+		if s.trace != nil && n > 0 && !s.readFirst.Swap(true) {
+			s.trace.firstByteRead(s.fd)
+		}
+	}
+}
+
+func (s *socket) connStats() ConnStats {
+	if s == nil {
+		return ConnStats{}
+	}
+	return s.stats.read()
+}
+
+// SocketInfo describes a socket opened by the net package.
+type SocketInfo struct {
+	FD            int       // netdev socket file descriptor
+	Owner         string    // "TCPConn", "TCPListener", "UDPConn", "IPConn" or "TLSConn"
+	Network       string    // e.g. "tcp", "udp", "tls"
+	LocalAddr     Addr      // local address, if known
+	RemoteAddr    Addr      // remote address, if known
+	Created       time.Time // time the fd was obtained from the netdev
+	Stack         string    // creation stack, if enabled with SetSocketStacks
+	BytesSent     uint64    // bytes written to the socket
+	BytesReceived uint64    // bytes read from the socket
+}
+
+// OpenSockets returns the sockets currently opened by the net package, oldest
+// first.  A socket is open from the time its fd is obtained from the netdev
+// with Dial*, Listen* or Accept until Close is called.
+//
+// Sockets that stay open longer than expected are likely leaked; the Stack of
+// the SocketInfo tells where a leaked socket was created, if stack recording
+// is enabled with SetSocketStacks.
+func OpenSockets() []SocketInfo {
+	socketsMu.Lock()
+	list := make([]*socket, 0, len(sockets))
+	laddrs := make(map[*socket]Addr, len(sockets))
+	for s := range sockets {
+		list = append(list, s)
+		laddrs[s] = s.laddr
+	}
+	socketsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].created.Before(list[j].created)
+	})
+
+	infos := make([]SocketInfo, len(list))
+	for i, s := range list {
+		infos[i] = SocketInfo{
+			FD:            s.fd,
+			Owner:         s.owner,
+			Network:       s.net,
+			LocalAddr:     laddrs[s],
+			RemoteAddr:    s.raddr,
+			Created:       s.created,
+			Stack:         formatStack(s.stack),
+			BytesSent:     s.stats.bytesSent.Load(),
+			BytesReceived: s.stats.bytesReceived.Load(),
+		}
+	}
+	return infos
+}
+
+// SetSocketStacks enables or disables recording the call stack of the code
+// creating each socket.  Recording is off by default as it costs an
+// allocation and a stack walk per socket.  Only sockets created while
+// recording is enabled report a Stack in OpenSockets.
+func SetSocketStacks(enabled bool) {
+	socketStacks.Store(enabled)
+}
+
+func formatStack(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(netItoa(frame.Line))
+		b.WriteByte('\n')
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,96 @@
+// Open socket registry tests
+
+package net
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+// connectNetdev is a netdev whose Connect blocks until released, then
+// fails.
+type connectNetdev struct {
+	nopNetdev
+	connecting chan struct{}
+	release    chan struct{}
+
+	mu     sync.Mutex
+	closes int
+}
+
+var errConnectFailed = errors.New("connect failed")
+
+func (d *connectNetdev) Socket(domain int, stype int, protocol int) (int, error) {
+	return 42, nil
+}
+
+func (d *connectNetdev) Connect(sockfd int, host string, ip netip.AddrPort) error {
+	close(d.connecting)
+	<-d.release
+	return errConnectFailed
+}
+
+func (d *connectNetdev) Close(sockfd int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closes++
+	return nil
+}
+
+func openSocket(fd int) *SocketInfo {
+	for _, si := range OpenSockets() {
+		if si.FD == fd {
+			return &si
+		}
+	}
+	return nil
+}
+
+func TestOpenSocketsConnecting(t *testing.T) {
+	dev := &connectNetdev{connecting: make(chan struct{}), release: make(chan struct{})}
+	oldNetdev := netdev
+	netdev = dev
+	t.Cleanup(func() { netdev = oldNetdev })
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := DialTCP("tcp", nil, &TCPAddr{IP: IP{192, 0, 2, 1}, Port: 80})
+		errc <- err
+	}()
+
+	// A socket still connecting is listed
+	select {
+	case <-dev.connecting:
+	case err := <-errc:
+		t.Fatalf("DialTCP = %v before connecting", err)
+	}
+	si := openSocket(42)
+	if si == nil {
+		t.Fatal("connecting socket not in OpenSockets")
+	}
+	if si.Owner != "TCPConn" || si.RemoteAddr.String() != "192.0.2.1:80" {
+		t.Errorf("OpenSockets lists %s to %v, want TCPConn to 192.0.2.1:80", si.Owner, si.RemoteAddr)
+	}
+
+	// A failed connect closes and unlists it
+	close(dev.release)
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errConnectFailed) {
+			t.Errorf("DialTCP = %v, want %v", err, errConnectFailed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DialTCP didn't return")
+	}
+	if openSocket(42) != nil {
+		t.Errorf("failed socket still in OpenSockets")
+	}
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.closes != 1 {
+		t.Errorf("failed socket closed %d times, want 1", dev.closes)
+	}
+}
@@ -0,0 +1,492 @@
+// Package socks implements a SOCKS5 client (RFC 1928) with username/password
+// authentication (RFC 1929), dialing through a proxy over the net package's
+// TCPConn.  Both CONNECT ("tcp" networks) and UDP ASSOCIATE ("udp"
+// networks) are supported.
+//
+// A Dialer plugs into the HTTP client to send requests through the proxy:
+//
+//	d := socks.NewDialer("proxy.example.com:1080")
+//	d.Auth = &socks.Auth{Username: "user", Password: "secret"}
+//	http.DefaultClient.Transport = &http.Transport{DialContext: d.DialContext}
+package socks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	version5 = 0x05
+
+	cmdConnect      = 0x01
+	cmdUDPAssociate = 0x03
+
+	authNone         = 0x00
+	authPassword     = 0x02
+	authNoAcceptable = 0xff
+
+	authPasswordVersion = 0x01
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+)
+
+// A ContextDialer dials connections, like net.Dialer.  Dialer is a
+// ContextDialer, so SOCKS5 proxies can be chained.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Auth holds the credentials for username/password authentication.
+type Auth struct {
+	Username string
+	Password string
+}
+
+// A Dialer dials connections through a SOCKS5 proxy.
+type Dialer struct {
+	// ProxyAddress is the host:port address of the proxy.
+	ProxyAddress string
+
+	// Auth, if not nil, holds the credentials to authenticate with.
+	// Otherwise no authentication is offered to the proxy.
+	Auth *Auth
+
+	// LocalResolve makes the Dialer resolve host names itself, with the
+	// netdev.  By default host names are sent to the proxy to resolve,
+	// which works where local DNS is blocked.
+	LocalResolve bool
+
+	// Forward dials the TCP connection to the proxy, and the UDP
+	// association's relay.  If nil, a zero net.Dialer is used.
+	Forward ContextDialer
+}
+
+// NewDialer returns a Dialer dialing through the SOCKS5 proxy at
+// proxyAddress, without authentication.
+func NewDialer(proxyAddress string) *Dialer {
+	return &Dialer{ProxyAddress: proxyAddress}
+}
+
+// Addr is an address reported by the proxy.  Either Name or IP is set.
+type Addr struct {
+	Name string
+	IP   net.IP
+	Port int
+}
+
+func (a *Addr) Network() string { return "socks" }
+
+func (a *Addr) String() string {
+	if a == nil {
+		return "<nil>"
+	}
+	host := a.Name
+	if a.IP != nil {
+		host = a.IP.String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(a.Port))
+}
+
+// A ReplyError is a failure reply of the proxy to a request.
+type ReplyError byte
+
+func (e ReplyError) Error() string {
+	switch e {
+	case 0x01:
+		return "general SOCKS server failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "TTL expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	}
+	return "unknown SOCKS reply " + strconv.Itoa(int(e))
+}
+
+var (
+	errBadVersion   = errors.New("unexpected protocol version")
+	errNoAuthMethod = errors.New("no acceptable authentication methods")
+	errAuthFailed   = errors.New("username/password authentication failed")
+	errBadAddress   = errors.New("bad address")
+)
+
+// Conn is a connection through the proxy, established with CONNECT.
+type Conn struct {
+	net.Conn
+
+	bound *Addr
+}
+
+// BoundAddr returns the address the proxy bound to connect to the
+// destination.
+func (c *Conn) BoundAddr() net.Addr {
+	return c.bound
+}
+
+// Dial connects to the address on the named network through the proxy.
+//
+// Dial uses context.Background internally; to specify the context, use
+// DialContext.
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network through the
+// proxy, using the provided context for the dial to the proxy and the
+// SOCKS5 exchange.  The network must be "tcp" or "tcp4", for a Conn, or
+// "udp" or "udp4", for a datagram connection through a UDP association.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var cmd byte
+	switch network {
+	case "tcp", "tcp4":
+		cmd = cmdConnect
+	case "udp", "udp4":
+		cmd = cmdUDPAssociate
+	default:
+		return nil, d.opError(network, nil, net.UnknownNetworkError(network))
+	}
+
+	dst, err := d.target(address)
+	if err != nil {
+		return nil, d.opError(network, nil, err)
+	}
+
+	c, err := d.forward().DialContext(ctx, "tcp", d.ProxyAddress)
+	if err != nil {
+		return nil, d.opError(network, dst, err)
+	}
+
+	req := dst
+	if cmd == cmdUDPAssociate {
+		// The destination of the datagrams isn't known to the proxy
+		// yet; ask for a relay accepting datagrams from any port
+		req = &Addr{IP: net.IPv4zero}
+	}
+	bound, err := d.handshake(ctx, c, cmd, req)
+	if err != nil {
+		c.Close()
+		return nil, d.opError(network, dst, err)
+	}
+
+	if cmd == cmdConnect {
+		return &Conn{Conn: c, bound: bound}, nil
+	}
+
+	uc, err := d.dialRelay(ctx, c, bound, dst)
+	if err != nil {
+		c.Close()
+		return nil, d.opError(network, dst, err)
+	}
+	return uc, nil
+}
+
+func (d *Dialer) forward() ContextDialer {
+	if d.Forward != nil {
+		return d.Forward
+	}
+	return &net.Dialer{}
+}
+
+func (d *Dialer) opError(network string, dst *Addr, err error) error {
+	var addr net.Addr
+	if dst != nil {
+		addr = dst
+	}
+	return &net.OpError{Op: "socks", Net: network, Source: nil, Addr: addr, Err: err}
+}
+
+// target parses the destination address, resolving the host name if
+// d.LocalResolve.
+func (d *Dialer) target(address string) (*Addr, error) {
+	host, sport, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(sport)
+	if err != nil || port < 1 || port > 0xffff {
+		return nil, errors.New("bad port " + sport)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return &Addr{IP: ip, Port: port}, nil
+	}
+	if len(host) > 255 {
+		return nil, errBadAddress
+	}
+	if d.LocalResolve {
+		ipaddr, err := net.ResolveIPAddr("ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		return &Addr{IP: ipaddr.IP, Port: port}, nil
+	}
+	return &Addr{Name: host, Port: port}, nil
+}
+
+// appendAddr appends the SOCKS5 encoding of a: ATYP, address and port.
+func appendAddr(b []byte, a *Addr) []byte {
+	switch {
+	case a.IP.To4() != nil:
+		b = append(b, atypIPv4)
+		b = append(b, a.IP.To4()...)
+	case a.IP != nil:
+		b = append(b, atypIPv6)
+		b = append(b, a.IP.To16()...)
+	default:
+		b = append(b, atypDomain, byte(len(a.Name)))
+		b = append(b, a.Name...)
+	}
+	return append(b, byte(a.Port>>8), byte(a.Port))
+}
+
+// parseAddr parses the SOCKS5 encoding of an address at the start of b,
+// returning the address and its encoded length.
+func parseAddr(b []byte) (*Addr, int, error) {
+	if len(b) < 1 {
+		return nil, 0, errBadAddress
+	}
+	var a Addr
+	var n int
+	switch b[0] {
+	case atypIPv4:
+		n = 1 + net.IPv4len
+		if len(b) < n+2 {
+			return nil, 0, errBadAddress
+		}
+		a.IP = net.IP(append([]byte(nil), b[1:n]...))
+	case atypIPv6:
+		n = 1 + net.IPv6len
+		if len(b) < n+2 {
+			return nil, 0, errBadAddress
+		}
+		a.IP = net.IP(append([]byte(nil), b[1:n]...))
+	case atypDomain:
+		if len(b) < 2 {
+			return nil, 0, errBadAddress
+		}
+		n = 2 + int(b[1])
+		if len(b) < n+2 {
+			return nil, 0, errBadAddress
+		}
+		a.Name = string(b[2:n])
+	default:
+		return nil, 0, errBadAddress
+	}
+	a.Port = int(b[n])<<8 | int(b[n+1])
+	return &a, n + 2, nil
+}
+
+// readAddr reads the SOCKS5 encoding of an address from r.
+func readAddr(r io.Reader) (*Addr, error) {
+	b := make([]byte, 2, 2+255+2)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	var rest int
+	switch b[0] {
+	case atypIPv4:
+		rest = net.IPv4len - 1 + 2
+	case atypIPv6:
+		rest = net.IPv6len - 1 + 2
+	case atypDomain:
+		rest = int(b[1]) + 2
+	default:
+		return nil, errBadAddress
+	}
+	b = b[:2+rest]
+	if _, err := io.ReadFull(r, b[2:]); err != nil {
+		return nil, err
+	}
+	a, _, err := parseAddr(b)
+	return a, err
+}
+
+// handshake authenticates with the proxy over c and sends the cmd request
+// for dst, returning the address bound by the proxy.  The exchange is
+// bounded by ctx.
+func (d *Dialer) handshake(ctx context.Context, c net.Conn, cmd byte, dst *Addr) (bound *Addr, err error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	// Interrupt the exchange by closing c if ctx is done first
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer func() {
+		if !stop() {
+			err = ctx.Err()
+		}
+	}()
+
+	// Method selection
+	b := make([]byte, 0, 3+2+255+255)
+	b = append(b, version5, 1, authNone)
+	if d.Auth != nil {
+		b[1] = 2
+		b = append(b, authPassword)
+	}
+	if _, err := c.Write(b); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(c, b[:2]); err != nil {
+		return nil, err
+	}
+	if b[0] != version5 {
+		return nil, errBadVersion
+	}
+
+	switch method := b[1]; {
+	case method == authNone:
+	case method == authPassword && d.Auth != nil:
+		user, pass := d.Auth.Username, d.Auth.Password
+		if len(user) < 1 || len(user) > 255 || len(pass) > 255 {
+			return nil, errors.New("invalid username/password")
+		}
+		b = append(b[:0], authPasswordVersion, byte(len(user)))
+		b = append(b, user...)
+		b = append(b, byte(len(pass)))
+		b = append(b, pass...)
+		if _, err := c.Write(b); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(c, b[:2]); err != nil {
+			return nil, err
+		}
+		if b[0] != authPasswordVersion || b[1] != 0x00 {
+			return nil, errAuthFailed
+		}
+	default:
+		return nil, errNoAuthMethod
+	}
+
+	// Request
+	b = append(b[:0], version5, cmd, 0x00)
+	b = appendAddr(b, dst)
+	if _, err := c.Write(b); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(c, b[:3]); err != nil {
+		return nil, err
+	}
+	if b[0] != version5 {
+		return nil, errBadVersion
+	}
+	if b[1] != 0x00 {
+		return nil, ReplyError(b[1])
+	}
+	return readAddr(c)
+}
+
+// dialRelay dials the UDP relay bound by the proxy for the association
+// controlled by ctrl, returning a datagram Conn to dst.
+func (d *Dialer) dialRelay(ctx context.Context, ctrl net.Conn, relay, dst *Addr) (net.Conn, error) {
+	host := relay.Name
+	if relay.IP != nil {
+		host = relay.IP.String()
+	}
+	if relay.IP != nil && relay.IP.IsUnspecified() {
+		// The relay is on the proxy's address
+		if ta, ok := ctrl.RemoteAddr().(*net.TCPAddr); ok {
+			host = ta.IP.String()
+		}
+	}
+	c, err := d.forward().DialContext(ctx, "udp", net.JoinHostPort(host, strconv.Itoa(relay.Port)))
+	if err != nil {
+		return nil, err
+	}
+	header := appendAddr([]byte{0x00, 0x00, 0x00}, dst)
+	return &udpConn{Conn: c, ctrl: ctrl, header: header, raddr: dst}, nil
+}
+
+// udpConn is a datagram connection through a UDP association.  Each
+// datagram exchanged with the relay starts with a SOCKS5 UDP request
+// header: RSV(2), FRAG(1) and the destination or source address.
+type udpConn struct {
+	net.Conn          // to the relay
+	ctrl     net.Conn // the association lasts as long as ctrl is open
+	header   []byte
+	raddr    *Addr
+
+	wmu  sync.Mutex
+	wbuf []byte // header and payload of the datagram written
+	rmu  sync.Mutex
+	rbuf []byte // header and payload of the datagram read
+}
+
+func (c *udpConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+func (c *udpConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.wbuf = append(append(c.wbuf[:0], c.header...), b...)
+	if _, err := c.Conn.Write(c.wbuf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *udpConn) Read(b []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if n := len(b) + 3 + 2 + 255 + 2; cap(c.rbuf) < n {
+		c.rbuf = make([]byte, n)
+	}
+	buf := c.rbuf[:cap(c.rbuf)]
+	for {
+		n, err := c.Conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n < 4 || buf[2] != 0x00 {
+			// Fragments aren't supported; drop them
+			continue
+		}
+		alen, ok := c.fromDst(buf[3:n])
+		if !ok {
+			// Not from the address the Conn is "connected" to
+			continue
+		}
+		return copy(b, buf[3+alen:n]), nil
+	}
+}
+
+// fromDst reports whether the encoded source address at the start of b is
+// the destination, and returns its encoded length.  A destination given
+// by name matches a source of that name, or of any IP address with the
+// same port, as the relay may report the address it resolved the name to.
+func (c *udpConn) fromDst(b []byte) (int, bool) {
+	if dst := c.header[3:]; c.raddr.IP != nil {
+		// Compare the encodings, to not allocate the parsed address
+		return len(dst), len(b) >= len(dst) && bytes.Equal(b[:len(dst)], dst)
+	}
+	src, n, err := parseAddr(b)
+	if err != nil || src.Port != c.raddr.Port {
+		return 0, false
+	}
+	return n, src.IP != nil || src.Name == c.raddr.Name
+}
+
+func (c *udpConn) Close() error {
+	err := c.Conn.Close()
+	if cerr := c.ctrl.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
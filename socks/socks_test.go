@@ -0,0 +1,341 @@
+// SOCKS5 client tests against an in-memory proxy
+
+package socks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// proxy is a scripted SOCKS5 server on a net.PipeListener
+type proxy struct {
+	t *testing.T
+	l *net.PipeListener
+
+	method byte   // method to select
+	authOK bool   // accept the username/password
+	reply  byte   // reply code to the request
+	bound  []byte // encoded bound address
+
+	// recorded
+	methods []byte
+	user    string
+	pass    string
+	cmd     byte
+	dst     *Addr
+}
+
+func newProxy(t *testing.T) *proxy {
+	p := &proxy{
+		t:      t,
+		l:      net.ListenPipe(),
+		method: authNone,
+		authOK: true,
+		bound:  []byte{atypIPv4, 10, 0, 0, 1, 0x04, 0xd2}, // 10.0.0.1:1234
+	}
+	t.Cleanup(func() { p.l.Close() })
+	return p
+}
+
+// dialer returns a Dialer dialing the proxy
+func (p *proxy) dialer() *Dialer {
+	d := NewDialer(p.l.Addr().String())
+	d.Forward = p.l
+	return d
+}
+
+// serve runs one exchange, then echoes the connection.  It returns when
+// the exchange is done.
+func (p *proxy) serve() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err := p.l.Accept()
+		if err != nil {
+			return
+		}
+		if !p.exchange(c) {
+			c.Close()
+			return
+		}
+		go func() {
+			io.Copy(c, c)
+			c.Close()
+		}()
+	}()
+	return done
+}
+
+func (p *proxy) exchange(c net.Conn) bool {
+	b := make([]byte, 2, 512)
+	if _, err := io.ReadFull(c, b); err != nil || b[0] != version5 {
+		p.t.Errorf("proxy: bad method selection %x, %v", b, err)
+		return false
+	}
+	p.methods = make([]byte, b[1])
+	io.ReadFull(c, p.methods)
+	c.Write([]byte{version5, p.method})
+
+	switch p.method {
+	case authNoAcceptable:
+		return false
+	case authPassword:
+		// The client hangs up if it didn't offer the method
+		b = b[:2]
+		if _, err := io.ReadFull(c, b); err != nil {
+			return false
+		}
+		user := make([]byte, b[1])
+		io.ReadFull(c, user)
+		io.ReadFull(c, b[:1])
+		pass := make([]byte, b[0])
+		io.ReadFull(c, pass)
+		p.user, p.pass = string(user), string(pass)
+		if !p.authOK {
+			c.Write([]byte{authPasswordVersion, 0x01})
+			return false
+		}
+		c.Write([]byte{authPasswordVersion, 0x00})
+	}
+
+	b = b[:3]
+	if _, err := io.ReadFull(c, b); err != nil || b[0] != version5 {
+		p.t.Errorf("proxy: bad request %x, %v", b, err)
+		return false
+	}
+	p.cmd = b[1]
+	dst, err := readAddr(c)
+	if err != nil {
+		p.t.Errorf("proxy: bad request address: %v", err)
+		return false
+	}
+	p.dst = dst
+	c.Write(append([]byte{version5, p.reply, 0x00}, p.bound...))
+	return p.reply == 0x00
+}
+
+func TestDialConnect(t *testing.T) {
+	p := newProxy(t)
+	done := p.serve()
+
+	c, err := p.dialer().Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-done
+
+	if !bytes.Equal(p.methods, []byte{authNone}) {
+		t.Errorf("offered methods %x, want %x", p.methods, []byte{authNone})
+	}
+	if p.cmd != cmdConnect {
+		t.Errorf("command %#x, want CONNECT", p.cmd)
+	}
+	if p.dst.Name != "example.com" || p.dst.IP != nil || p.dst.Port != 80 {
+		t.Errorf("destination %v, want the unresolved example.com:80", p.dst)
+	}
+	if got := c.(*Conn).BoundAddr().String(); got != "10.0.0.1:1234" {
+		t.Errorf("BoundAddr() = %s, want 10.0.0.1:1234", got)
+	}
+
+	// The Conn carries the traffic once connected
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(c, b); err != nil || string(b) != "hello" {
+		t.Errorf("read %q, %v, want %q", b, err, "hello")
+	}
+}
+
+func TestDialPassword(t *testing.T) {
+	p := newProxy(t)
+	p.method = authPassword
+	done := p.serve()
+
+	d := p.dialer()
+	d.Auth = &Auth{Username: "user", Password: "secret"}
+	c, err := d.Dial("tcp", "192.0.2.1:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	<-done
+
+	if !bytes.Equal(p.methods, []byte{authNone, authPassword}) {
+		t.Errorf("offered methods %x, want %x", p.methods, []byte{authNone, authPassword})
+	}
+	if p.user != "user" || p.pass != "secret" {
+		t.Errorf("credentials %q/%q, want user/secret", p.user, p.pass)
+	}
+	if !p.dst.IP.Equal(net.IPv4(192, 0, 2, 1)) || p.dst.Port != 443 {
+		t.Errorf("destination %v, want 192.0.2.1:443", p.dst)
+	}
+}
+
+var dialErrorTests = []struct {
+	name   string
+	method byte
+	authOK bool
+	reply  byte
+	err    error
+}{
+	{"auth failed", authPassword, false, 0x00, errAuthFailed},
+	{"no acceptable method", authNoAcceptable, true, 0x00, errNoAuthMethod},
+	{"password not offered", authPassword, true, 0x00, errNoAuthMethod},
+	{"connection refused", authNone, true, 0x05, ReplyError(0x05)},
+	{"command not supported", authNone, true, 0x07, ReplyError(0x07)},
+}
+
+func TestDialErrors(t *testing.T) {
+	for _, tt := range dialErrorTests {
+		p := newProxy(t)
+		p.method, p.authOK, p.reply = tt.method, tt.authOK, tt.reply
+		done := p.serve()
+
+		d := p.dialer()
+		if tt.name != "password not offered" {
+			d.Auth = &Auth{Username: "user", Password: "wrong"}
+		}
+		c, err := d.Dial("tcp", "example.com:80")
+		if err == nil {
+			c.Close()
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Dial = %v, want %v", tt.name, err, tt.err)
+		}
+		var oe *net.OpError
+		if errors.As(err, &oe) && oe.Op != "socks" {
+			t.Errorf("%s: Dial error op %q, want socks", tt.name, oe.Op)
+		}
+		p.l.Close()
+		<-done
+	}
+}
+
+func TestDialContextTimeout(t *testing.T) {
+	p := newProxy(t)
+	// The proxy accepts but never answers
+	go func() {
+		c, err := p.l.Accept()
+		if err == nil {
+			defer c.Close()
+			io.Copy(io.Discard, c)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := p.dialer().DialContext(ctx, "tcp", "example.com:80")
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("DialContext = %v, want a deadline error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("DialContext gave up after %v", d)
+	}
+}
+
+func TestDialBadTargets(t *testing.T) {
+	d := NewDialer("proxy:1080")
+	for _, tt := range []struct{ network, address string }{
+		{"tcp6", "example.com:80"},
+		{"tcp", "example.com"},
+		{"tcp", "example.com:0"},
+		{"tcp", "example.com:65536"},
+	} {
+		if _, err := d.Dial(tt.network, tt.address); err == nil {
+			t.Errorf("Dial(%q, %q) succeeded", tt.network, tt.address)
+		}
+	}
+}
+
+var addrTests = []struct {
+	addr    *Addr
+	encoded []byte
+	str     string
+}{
+	{&Addr{IP: net.IPv4(10, 0, 0, 1), Port: 80}, []byte{atypIPv4, 10, 0, 0, 1, 0, 80}, "10.0.0.1:80"},
+	{&Addr{Name: "example.com", Port: 443}, append(append([]byte{atypDomain, 11}, "example.com"...), 0x01, 0xbb), "example.com:443"},
+	{&Addr{IP: net.ParseIP("2001:db8::1"), Port: 1080}, append(append([]byte{atypIPv6}, net.ParseIP("2001:db8::1")...), 0x04, 0x38), "[2001:db8::1]:1080"},
+}
+
+func TestAddrEncoding(t *testing.T) {
+	for i, tt := range addrTests {
+		b := appendAddr(nil, tt.addr)
+		if !bytes.Equal(b, tt.encoded) {
+			t.Errorf("#%d: appendAddr(%v) = %x, want %x", i, tt.addr, b, tt.encoded)
+		}
+		a, n, err := parseAddr(append(b, 0xff))
+		if err != nil || n != len(b) || a.String() != tt.str {
+			t.Errorf("#%d: parseAddr = %v, %d, %v, want %s, %d, nil", i, a, n, err, tt.str, len(b))
+		}
+		a, err = readAddr(bytes.NewReader(b))
+		if err != nil || a.String() != tt.str {
+			t.Errorf("#%d: readAddr = %v, %v, want %s, nil", i, a, err, tt.str)
+		}
+	}
+
+	for _, b := range [][]byte{
+		nil,
+		{atypIPv4, 10, 0, 0},
+		{atypDomain, 5, 'a', 'b'},
+		{0x02, 0, 0},
+	} {
+		if _, _, err := parseAddr(b); err != errBadAddress {
+			t.Errorf("parseAddr(%x) = %v, want %v", b, err, errBadAddress)
+		}
+	}
+}
+
+func TestUDPConn(t *testing.T) {
+	for _, dst := range []*Addr{
+		{IP: net.IPv4(192, 0, 2, 1), Port: 53},
+		{Name: "example.com", Port: 53},
+	} {
+		relay, peer := net.Pipe()
+		ctrl, _ := net.Pipe()
+		c := &udpConn{Conn: relay, ctrl: ctrl, header: appendAddr([]byte{0x00, 0x00, 0x00}, dst), raddr: dst}
+
+		// Datagrams are written with the header
+		go c.Write([]byte("query"))
+		b := make([]byte, 512)
+		n, _ := peer.Read(b)
+		if want := append(appendAddr([]byte{0x00, 0x00, 0x00}, dst), "query"...); !bytes.Equal(b[:n], want) {
+			t.Errorf("%v: wrote %x, want %x", dst, b[:n], want)
+		}
+
+		// Only datagrams from dst are read
+		type datagram struct {
+			frag byte
+			src  *Addr
+			data string
+		}
+		datagrams := []datagram{
+			{0x00, &Addr{IP: net.IPv4(192, 0, 2, 1), Port: 54}, "other port"},
+			{0x00, &Addr{Name: "example.org", Port: 53}, "other name"},
+			{0x01, dst, "fragment"},
+			{0x00, dst, "reply"},
+		}
+		if dst.IP != nil {
+			// A name is matched by any address it may resolve to
+			datagrams = append([]datagram{{0x00, &Addr{IP: net.IPv4(192, 0, 2, 2), Port: 53}, "other host"}}, datagrams...)
+		}
+		go func() {
+			for _, d := range datagrams {
+				peer.Write(append(appendAddr([]byte{0x00, 0x00, d.frag}, d.src), d.data...))
+			}
+		}()
+		n, err := c.Read(b)
+		if err != nil || string(b[:n]) != "reply" {
+			t.Errorf("%v: Read = %q, %v, want %q, nil", dst, b[:n], err, "reply")
+		}
+		c.Close()
+	}
+}
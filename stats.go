@@ -0,0 +1,171 @@
+// Traffic statistics
+
+package net
+
+import (
+	"io"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats holds the traffic counters of a connection, or the totals of all
+// connections in Stats.  A packet is one successful netdev Send or Recv call.
+type ConnStats struct {
+	BytesSent       uint64
+	BytesReceived   uint64
+	PacketsSent     uint64
+	PacketsReceived uint64
+	SendErrors      uint64
+	RecvErrors      uint64
+}
+
+// DialLatencyBuckets are the upper bounds of the Stats.DialLatency histogram
+// buckets.  The last bucket of DialLatency counts dials slower than the last
+// bound.
+var DialLatencyBuckets = [...]time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Stats holds the net package's global traffic statistics.
+type Stats struct {
+	// ConnStats are the totals of all connections, open or closed
+	ConnStats
+
+	SocketsOpened uint64 // fds obtained from netdev.Socket or Accept
+	SocketsClosed uint64 // fds closed
+
+	Dials      uint64 // TCP, UDP and TLS dials attempted
+	DialErrors uint64 // dials failed
+
+	// DialLatency is a histogram of the time taken by successful dials,
+	// from socket creation to connect done.  Bucket i counts the dials
+	// taking at most DialLatencyBuckets[i].
+	DialLatency [len(DialLatencyBuckets) + 1]uint64
+
+	Lookups      uint64 // host names resolved with netdev.GetHostByName
+	LookupErrors uint64 // host name resolutions failed
+}
+
+// netStats is the global counterpart of Stats; all fields are updated
+// atomically so counting never allocates or takes a lock.
+var netStats struct {
+	bytesSent       atomic.Uint64
+	bytesReceived   atomic.Uint64
+	packetsSent     atomic.Uint64
+	packetsReceived atomic.Uint64
+	sendErrors      atomic.Uint64
+	recvErrors      atomic.Uint64
+	socketsOpened   atomic.Uint64
+	socketsClosed   atomic.Uint64
+	dials           atomic.Uint64
+	dialErrors      atomic.Uint64
+	dialLatency     [len(DialLatencyBuckets) + 1]atomic.Uint64
+	lookups         atomic.Uint64
+	lookupErrors    atomic.Uint64
+}
+
+// ReadStats populates s with the net package's global traffic statistics.
+//
+// Each counter is read atomically, but the counters are not read as one
+// consistent snapshot.
+func ReadStats(s *Stats) {
+	s.BytesSent = netStats.bytesSent.Load()
+	s.BytesReceived = netStats.bytesReceived.Load()
+	s.PacketsSent = netStats.packetsSent.Load()
+	s.PacketsReceived = netStats.packetsReceived.Load()
+	s.SendErrors = netStats.sendErrors.Load()
+	s.RecvErrors = netStats.recvErrors.Load()
+	s.SocketsOpened = netStats.socketsOpened.Load()
+	s.SocketsClosed = netStats.socketsClosed.Load()
+	s.Dials = netStats.dials.Load()
+	s.DialErrors = netStats.dialErrors.Load()
+	for i := range s.DialLatency {
+		s.DialLatency[i] = netStats.dialLatency[i].Load()
+	}
+	s.Lookups = netStats.lookups.Load()
+	s.LookupErrors = netStats.lookupErrors.Load()
+}
+
+// connStats counts the traffic of one socket
+type connStats struct {
+	bytesSent       atomic.Uint64
+	bytesReceived   atomic.Uint64
+	packetsSent     atomic.Uint64
+	packetsReceived atomic.Uint64
+	sendErrors      atomic.Uint64
+	recvErrors      atomic.Uint64
+}
+
+func (cs *connStats) countSend(n int, err error) {
+	if n > 0 {
+		cs.bytesSent.Add(uint64(n))
+		cs.packetsSent.Add(1)
+		netStats.bytesSent.Add(uint64(n))
+		netStats.packetsSent.Add(1)
+	}
+	if err != nil {
+		cs.sendErrors.Add(1)
+		netStats.sendErrors.Add(1)
+	}
+}
+
+func (cs *connStats) countRecv(n int, err error) {
+	if n > 0 {
+		cs.bytesReceived.Add(uint64(n))
+		cs.packetsReceived.Add(1)
+		netStats.bytesReceived.Add(uint64(n))
+		netStats.packetsReceived.Add(1)
+	}
+	// EOF is the orderly end of the stream, not an error
+	if err != nil && err != io.EOF {
+		cs.recvErrors.Add(1)
+		netStats.recvErrors.Add(1)
+	}
+}
+
+func (cs *connStats) read() ConnStats {
+	return ConnStats{
+		BytesSent:       cs.bytesSent.Load(),
+		BytesReceived:   cs.bytesReceived.Load(),
+		PacketsSent:     cs.packetsSent.Load(),
+		PacketsReceived: cs.packetsReceived.Load(),
+		SendErrors:      cs.sendErrors.Load(),
+		RecvErrors:      cs.recvErrors.Load(),
+	}
+}
+
+// countDial records the outcome of a dial started at start
+func countDial(start time.Time, err error) {
+	netStats.dials.Add(1)
+	if err != nil {
+		netStats.dialErrors.Add(1)
+		return
+	}
+	d := time.Since(start)
+	i := 0
+	for i < len(DialLatencyBuckets) && d > DialLatencyBuckets[i] {
+		i++
+	}
+	netStats.dialLatency[i].Add(1)
+}
+
+// lookupHost resolves host using the netdev resolver
+func lookupHost(trace *SocketTrace, host string) (netip.Addr, error) {
+	trace.resolveStart(host)
+	netStats.lookups.Add(1)
+	ip, err := netdev.GetHostByName(host)
+	if err != nil {
+		netStats.lookupErrors.Add(1)
+	}
+	trace.resolveDone(host, ip.AsSlice(), err)
+	return ip, err
+}
@@ -11,20 +11,34 @@ import (
 	"fmt"
 	"io"
 	"net/netip"
+	"os"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 )
 
-// KeepAliveConfig specifies the keep-alive probe configuration
-// for an active network connection, when supported by the
-// protocol and operating system.
-// TINYGO: Stub definition for compatibility; not used on embedded devices.
+// KeepAliveConfig contains TCP keep-alive options.
+//
+// If the Idle, Interval, or Count fields are zero, a default value is chosen.
+// If a field is negative, the corresponding socket-level option will be left unchanged.
 type KeepAliveConfig struct {
-	Enable   bool
-	Idle     time.Duration
+	// If Enable is true, keep-alive probes are enabled.
+	Enable bool
+
+	// Idle is the time that the connection must be idle before
+	// the first keep-alive probe is sent.
+	// If zero, a default value of 15 seconds is used.
+	Idle time.Duration
+
+	// Interval is the time between keep-alive probes.
+	// If zero, a default value of 15 seconds is used.
 	Interval time.Duration
-	Count    int
+
+	// Count is the maximum number of keep-alive probes that
+	// can go unanswered before dropping a connection.
+	// If zero, a default value of 9 is used.
+	Count int
 }
 
 // TCPAddr represents the address of a TCP end point.
@@ -92,6 +106,10 @@ func (a *TCPAddr) opAddr() Addr {
 // See func [Dial] for a description of the network and address
 // parameters.
 func ResolveTCPAddr(network, address string) (*TCPAddr, error) {
+	return resolveTCPAddr(nil, network, address)
+}
+
+func resolveTCPAddr(trace *SocketTrace, network, address string) (*TCPAddr, error) {
 
 	switch network {
 	case "tcp", "tcp4":
@@ -102,6 +120,8 @@ func ResolveTCPAddr(network, address string) (*TCPAddr, error) {
 	switch address {
 	case ":http":
 		address = ":80"
+	case ":https":
+		address = ":443"
 	}
 
 	// TINYGO: Use netdev resolver
@@ -121,7 +141,7 @@ func ResolveTCPAddr(network, address string) (*TCPAddr, error) {
 		return &TCPAddr{Port: port}, nil
 	}
 
-	ip, err := netdev.GetHostByName(host)
+	ip, err := lookupHost(trace, host)
 	if err != nil {
 		return nil, fmt.Errorf("Lookup of host name '%s' failed: %s", host, err)
 	}
@@ -149,6 +169,7 @@ type TCPConn struct {
 	raddr         *TCPAddr
 	readDeadline  time.Time
 	writeDeadline time.Time
+	sock          *socket
 }
 
 // DialTCP acts like Dial for TCP networks.
@@ -159,6 +180,10 @@ type TCPConn struct {
 // If the IP field of raddr is nil or an unspecified IP address, the
 // local system is assumed.
 func DialTCP(network string, laddr, raddr *TCPAddr) (*TCPConn, error) {
+	return dialTCP(defaultSocketTrace.Load(), nil, network, laddr, raddr)
+}
+
+func dialTCP(trace *SocketTrace, ctrl controlFunc, network string, laddr, raddr *TCPAddr) (*TCPConn, error) {
 
 	switch network {
 	case "tcp", "tcp4":
@@ -178,23 +203,51 @@ func DialTCP(network string, laddr, raddr *TCPAddr) (*TCPConn, error) {
 		return nil, errors.New("only ipv4 supported")
 	}
 
+	start := time.Now()
+
 	fd, err := netdev.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
+	trace.socketCreated(fd, network, err)
 	if err != nil {
+		countDial(start, err)
 		return nil, err
 	}
+	// Registered now so a hung connect shows in OpenSockets
+	sock := newSocket(fd, "TCPConn", network, laddr.opAddr(), raddr, trace)
+
+	if err := runControl(ctrl, fd, raddr.String()); err != nil {
+		sock.close()
+		countDial(start, err)
+		return nil, err
+	}
+
+	if laddr != nil {
+		err = netdev.Bind(fd, laddr.AddrPort())
+		trace.bind(fd, laddr, err)
+		if err != nil {
+			sock.close()
+			countDial(start, err)
+			return nil, err
+		}
+	}
 
 	rip, _ := netip.AddrFromSlice(raddr.IP)
 	raddrport := netip.AddrPortFrom(rip, uint16(raddr.Port))
-	if err = netdev.Connect(fd, "", raddrport); err != nil {
-		netdev.Close(fd)
+	trace.connectStart(fd, network, raddr)
+	err = netdev.Connect(fd, "", raddrport)
+	trace.connectDone(fd, network, raddr, err)
+	if err != nil {
+		sock.close()
+		countDial(start, err)
 		return nil, err
 	}
+	countDial(start, nil)
 
 	return &TCPConn{
 		fd:    fd,
 		net:   network,
 		laddr: laddr,
 		raddr: raddr,
+		sock:  sock,
 	}, nil
 }
 
@@ -203,15 +256,23 @@ func DialTCP(network string, laddr, raddr *TCPAddr) (*TCPConn, error) {
 // SyscallConn returns a raw network connection.
 // This implements the [syscall.Conn] interface.
 func (c *TCPConn) SyscallConn() (syscall.RawConn, error) {
-	return nil, errors.New("SyscallConn not implemented")
+	if c.sock.closed.Load() {
+		return nil, &OpError{Op: "raw-conn", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	return &rawConn{fd: c.fd, net: c.net, laddr: c.laddr, raddr: c.raddr, sock: c.sock,
+		readDeadline: &c.readDeadline, writeDeadline: &c.writeDeadline}, nil
 }
 
 func (c *TCPConn) Read(b []byte) (int, error) {
+	if err := waitReadable(c.fd, c.readDeadline); err != nil {
+		return 0, &OpError{Op: "read", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
 	n, err := netdev.Recv(c.fd, b, 0, c.readDeadline)
 	// Turn the -1 socket error into 0 and let err speak for error
 	if n < 0 {
 		n = 0
 	}
+	c.sock.countRecv(n, err)
 	if err != nil && err != io.EOF {
 		err = &OpError{Op: "read", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
 	}
@@ -224,14 +285,111 @@ func (c *TCPConn) Write(b []byte) (int, error) {
 	if n < 0 {
 		n = 0
 	}
+	c.sock.countSend(n, err)
 	if err != nil {
 		err = &OpError{Op: "write", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
 	}
 	return n, err
 }
 
+// ReadFrom implements the [io.ReaderFrom] ReadFrom method.
+//
+// TINYGO: There is no sendfile; Buffers are sent with one netdev call if
+// the netdev supports scatter/gather, otherwise r is copied through a small
+// pooled buffer.
+func (c *TCPConn) ReadFrom(r io.Reader) (int64, error) {
+	if v, ok := r.(*Buffers); ok {
+		return c.writeBuffers(v)
+	}
+	n, err := genericReadFrom(c, r)
+	if err != nil && err != io.EOF {
+		err = &OpError{Op: "readfrom", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return n, err
+}
+
+// WriteTo implements the io.WriterTo WriteTo method.
+//
+// TINYGO: If the netdev can lend its receive buffer, received data is
+// written to w straight from it; otherwise it is copied through a small
+// pooled buffer.
+func (c *TCPConn) WriteTo(w io.Writer) (int64, error) {
+	br, ok := netdevExt[bufferReceiver](c.fd)
+	if !ok {
+		n, err := genericWriteTo(c, w)
+		if err != nil && err != io.EOF {
+			err = &OpError{Op: "writeto", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+		}
+		return n, err
+	}
+
+	var n int64
+	for {
+		var werr error
+		nr, err := br.RecvBuffer(c.fd, 0, c.readDeadline, func(b []byte) (int, error) {
+			nw, err := w.Write(b)
+			werr = err
+			return nw, err
+		})
+		// Turn the -1 socket error into 0 and let err speak for error
+		if nr < 0 {
+			nr = 0
+		}
+		n += int64(nr)
+		if werr != nil {
+			c.sock.countRecv(nr, nil)
+			return n, werr
+		}
+		c.sock.countRecv(nr, err)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, &OpError{Op: "writeto", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+		}
+	}
+}
+
+// writeBuffers implements buffersWriter for Buffers.WriteTo, sending the
+// buffers with as few netdev calls as possible.
+func (c *TCPConn) writeBuffers(v *Buffers) (int64, error) {
+	bs, ok := netdevExt[buffersSender](c.fd)
+	var n int64
+	for len(*v) > 0 {
+		var nw int
+		var err error
+		if ok {
+			nw, err = bs.SendBuffers(c.fd, *v, 0, c.writeDeadline)
+			// Turn the -1 socket error into 0 and let err speak for error
+			if nw < 0 {
+				nw = 0
+			}
+			c.sock.countSend(nw, err)
+			if err != nil {
+				err = &OpError{Op: "write", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+			}
+		} else {
+			nw, err = c.Write((*v)[0])
+		}
+		n += int64(nw)
+		v.consume(int64(nw))
+		if err != nil {
+			return n, err
+		}
+		if nw == 0 && len(*v) > 0 && len((*v)[0]) > 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
 func (c *TCPConn) Close() error {
-	return netdev.Close(c.fd)
+	return c.sock.close()
+}
+
+// Stats returns the traffic counters of the connection.
+func (c *TCPConn) Stats() ConnStats {
+	return c.sock.connStats()
 }
 
 func (c *TCPConn) LocalAddr() Addr {
@@ -263,13 +421,13 @@ func (c *TCPConn) SetDeadline(t time.Time) error {
 // On some operating systems after sec seconds have elapsed any remaining
 // unsent data may be discarded.
 func (c *TCPConn) SetLinger(sec int) error {
-	return netdev.SetSockOpt(c.fd, _SOL_SOCKET, _SO_LINGER, sec)
+	return c.setSockOpt(_SOL_SOCKET, _SO_LINGER, sec)
 }
 
 // SetKeepAlive sets whether the operating system should send
 // keep-alive messages on the connection.
 func (c *TCPConn) SetKeepAlive(keepalive bool) error {
-	return netdev.SetSockOpt(c.fd, _SOL_SOCKET, _SO_KEEPALIVE, keepalive)
+	return c.setSockOpt(_SOL_SOCKET, _SO_KEEPALIVE, keepalive)
 }
 
 // SetKeepAlivePeriod sets the duration the connection needs to
@@ -279,7 +437,78 @@ func (c *TCPConn) SetKeepAlive(keepalive bool) error {
 // will reset the KeepAliveInterval to the default system value, which is normally 1 second.
 func (c *TCPConn) SetKeepAlivePeriod(d time.Duration) error {
 	// Units are 1/2 seconds
-	return netdev.SetSockOpt(c.fd, _SOL_TCP, _TCP_KEEPINTVL, 2*d.Seconds())
+	return c.setSockOpt(_SOL_TCP, _TCP_KEEPINTVL, 2*d.Seconds())
+}
+
+// SetKeepAliveConfig configures keep-alive messages sent by the operating system.
+func (c *TCPConn) SetKeepAliveConfig(config KeepAliveConfig) error {
+	return setKeepAliveConfig(c.setSockOpt, config)
+}
+
+// setKeepAliveConfig sets the keep-alive options of config with set,
+// filling in the defaults.
+func setKeepAliveConfig(set func(level, opt int, value any) error, config KeepAliveConfig) error {
+	if err := set(_SOL_SOCKET, _SO_KEEPALIVE, config.Enable); err != nil {
+		return err
+	}
+	if !config.Enable {
+		return nil
+	}
+	if config.Idle == 0 {
+		config.Idle = defaultTCPKeepAliveIdle
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultTCPKeepAliveInterval
+	}
+	if config.Count == 0 {
+		config.Count = defaultTCPKeepAliveCount
+	}
+	if config.Idle > 0 {
+		if err := set(_SOL_TCP, _TCP_KEEPIDLE, config.Idle); err != nil {
+			return err
+		}
+	}
+	if config.Interval > 0 {
+		if err := set(_SOL_TCP, _TCP_KEEPINTVL, config.Interval); err != nil {
+			return err
+		}
+	}
+	if config.Count > 0 {
+		if err := set(_SOL_TCP, _TCP_KEEPCNT, config.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetNoDelay controls whether the operating system should delay
+// packet transmission in hopes of sending fewer packets (Nagle's
+// algorithm).  The default is true (no delay), meaning that data is
+// sent as soon as possible after a Write.
+func (c *TCPConn) SetNoDelay(noDelay bool) error {
+	return c.setSockOpt(_SOL_TCP, _TCP_NODELAY, noDelay)
+}
+
+// SetReadBuffer sets the size of the operating system's
+// receive buffer associated with the connection.
+func (c *TCPConn) SetReadBuffer(bytes int) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_RCVBUF, bytes)
+}
+
+// SetWriteBuffer sets the size of the operating system's
+// transmit buffer associated with the connection.
+func (c *TCPConn) SetWriteBuffer(bytes int) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_SNDBUF, bytes)
+}
+
+func (c *TCPConn) setSockOpt(level, opt int, value any) error {
+	if c.sock.closed.Load() {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	if err := netdev.SetSockOpt(c.fd, level, opt, value); err != nil {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return nil
 }
 
 func (c *TCPConn) SetReadDeadline(t time.Time) error {
@@ -292,59 +521,345 @@ func (c *TCPConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+// CloseRead shuts down the reading side of the TCP connection.
+// Most callers should just use Close.
+//
+// TINYGO: Requires netdev support for shutdown; otherwise the error wraps
+// errors.ErrUnsupported.
+func (c *TCPConn) CloseRead() error {
+	if c.sock.closed.Load() {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	if err := shutdown(c.fd, _SHUT_RD); err != nil {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return nil
+}
+
+// CloseWrite shuts down the writing side of the TCP connection.
+// Most callers should just use Close.
+//
+// TINYGO: Requires netdev support for shutdown; otherwise the error wraps
+// errors.ErrUnsupported.
 func (c *TCPConn) CloseWrite() error {
-	return fmt.Errorf("CloseWrite not implemented")
+	if c.sock.closed.Load() {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	if err := shutdown(c.fd, _SHUT_WR); err != nil {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return nil
+}
+
+// defaultBacklog is the listen backlog used when ListenConfig.Backlog is zero
+const defaultBacklog = 5
+
+// acceptDeadliner is an optional netdever extension for devices able to
+// bound a blocking Accept with a deadline.  On timeout, AcceptDeadline
+// returns os.ErrDeadlineExceeded.  Without it, Accept polls for the
+// deadline if SetPollIO is enabled, or else runs netdev.Accept in a
+// goroutine it can stop waiting on.
+type acceptDeadliner interface {
+	AcceptDeadline(sockfd int, deadline time.Time) (int, netip.AddrPort, error)
+}
+
+type acceptResult struct {
+	fd    int
+	raddr netip.AddrPort
+	err   error
 }
 
-type listener struct {
+// TCPListener is a TCP network listener. Clients should typically
+// use variables of type Listener instead of assuming TCP.
+type TCPListener struct {
 	fd    int
 	laddr *TCPAddr
+	sock  *socket
+	trace *SocketTrace
+
+	// TINYGO: The fields below implement Accept deadlines for netdevs
+	// without acceptDeadliner.  A single netdev.Accept runs in the
+	// background at a time, delivering to accepted.
+
+	mu       sync.Mutex
+	deadline time.Time
+	wake     chan struct{} // closed to wake Accept when the deadline changes
+	pending  bool          // netdev.Accept running
+	waiters  int           // Accept calls waiting on accepted
+	accepted chan acceptResult
+	done     chan struct{} // closed by Close
 }
 
-func (l *listener) Accept() (Conn, error) {
-	fd, raddr, err := netdev.Accept(l.fd)
+// ListenTCP acts like Listen for TCP networks.
+//
+// The network must be a TCP network name; see func Dial for details.
+//
+// If the IP field of laddr is nil or an unspecified IP address,
+// ListenTCP listens on all available unicast and anycast IP addresses
+// of the local system.
+// If the Port field of laddr is 0, a port number is automatically
+// chosen.
+func ListenTCP(network string, laddr *TCPAddr) (*TCPListener, error) {
+	switch network {
+	case "tcp", "tcp4":
+	default:
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: UnknownNetworkError(network)}
+	}
+	if laddr == nil {
+		laddr = &TCPAddr{}
+	}
+	return listenTCP(defaultSocketTrace.Load(), nil, network, laddr, defaultBacklog)
+}
+
+func listenTCP(trace *SocketTrace, ctrl controlFunc, network string, laddr *TCPAddr, backlog int) (*TCPListener, error) {
+	return listenStream(trace, ctrl, _IPPROTO_TCP, "TCPListener", network, laddr, backlog)
+}
+
+// listenStream creates a listening stream socket of protocol proto
+// (_IPPROTO_TCP or _IPPROTO_TLS).  owner is the socket's owner, for stats.
+func listenStream(trace *SocketTrace, ctrl controlFunc, proto int, owner, network string, laddr *TCPAddr, backlog int) (*TCPListener, error) {
+
+	// TINYGO: Use netdev to create the TCP socket, bind and listen
+
+	fd, err := netdev.Socket(_AF_INET, _SOCK_STREAM, proto)
+	trace.socketCreated(fd, network, err)
 	if err != nil {
-		return nil, err
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+	sock := newSocket(fd, owner, network, laddr, nil, trace)
+
+	if err := runControl(ctrl, fd, laddr.String()); err != nil {
+		sock.close()
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+
+	laddrport := laddr.AddrPort()
+	err = netdev.Bind(fd, laddrport)
+	trace.bind(fd, laddr, err)
+	if err != nil {
+		sock.close()
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+
+	err = netdev.Listen(fd, backlog)
+	trace.listen(fd, backlog, err)
+	if err != nil {
+		sock.close()
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: err}
+	}
+
+	return &TCPListener{
+		fd:       fd,
+		laddr:    laddr,
+		sock:     sock,
+		trace:    trace,
+		wake:     make(chan struct{}),
+		accepted: make(chan acceptResult, 1),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// SyscallConn returns a raw network connection.
+// This implements the [syscall.Conn] interface.
+//
+// The returned RawConn only supports calling Control. Read and
+// Write return an error.
+func (l *TCPListener) SyscallConn() (syscall.RawConn, error) {
+	if l.sock.closed.Load() {
+		return nil, &OpError{Op: "raw-conn", Net: "tcp", Source: nil, Addr: l.laddr, Err: ErrClosed}
+	}
+	return &rawListener{rawConn{fd: l.fd, net: "tcp", laddr: l.laddr, sock: l.sock}}, nil
+}
+
+// AcceptTCP accepts the next incoming call and returns the new
+// connection.
+func (l *TCPListener) AcceptTCP() (*TCPConn, error) {
+	if l.sock.closed.Load() {
+		return nil, &OpError{Op: "accept", Net: "tcp", Source: nil, Addr: l.Addr(), Err: ErrClosed}
+	}
+	fd, raddr, err := l.accept()
+	var tcpraddr *TCPAddr
+	if err == nil {
+		tcpraddr = TCPAddrFromAddrPort(raddr)
+	}
+	l.trace.accept(l.fd, fd, tcpraddr, err)
+	if err != nil {
+		return nil, &OpError{Op: "accept", Net: "tcp", Source: nil, Addr: l.Addr(), Err: err}
 	}
 
+	laddr := l.Addr().(*TCPAddr)
 	return &TCPConn{
 		fd:    fd,
 		net:   "tcp",
-		laddr: l.laddr,
-		raddr: TCPAddrFromAddrPort(raddr),
+		laddr: laddr,
+		raddr: tcpraddr,
+		sock:  newSocket(fd, "TCPConn", "tcp", laddr, tcpraddr, l.trace),
 	}, nil
 }
 
-func (l *listener) Close() error {
-	return netdev.Close(l.fd)
+// Accept implements the Accept method in the [Listener] interface; it
+// waits for the next call and returns a generic [Conn].
+func (l *TCPListener) Accept() (Conn, error) {
+	c, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
-func (l *listener) Addr() Addr {
-	return l.laddr
+// accept waits for netdev.Accept to return a connection, until the
+// listener's deadline or Close.
+func (l *TCPListener) accept() (int, netip.AddrPort, error) {
+	if ad, ok := netdevExt[acceptDeadliner](l.fd); ok {
+		l.mu.Lock()
+		deadline := l.deadline
+		l.mu.Unlock()
+		return ad.AcceptDeadline(l.fd, deadline)
+	}
+
+	if _, ok := netdevExt[netdevPoller](l.fd); ok && pollIO.Load() {
+		l.mu.Lock()
+		deadline := l.deadline
+		l.mu.Unlock()
+		if err := waitReadable(l.fd, deadline); err != nil {
+			return -1, netip.AddrPort{}, err
+		}
+		return netdev.Accept(l.fd)
+	}
+
+	l.mu.Lock()
+	l.waiters++
+	defer func() {
+		l.mu.Lock()
+		l.waiters--
+		l.mu.Unlock()
+	}()
+	for {
+		if !l.pending && len(l.accepted) == 0 {
+			l.pending = true
+			go l.acceptBackground()
+		}
+		deadline, wake := l.deadline, l.wake
+		l.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timer = time.NewTimer(time.Until(deadline))
+			timeout = timer.C
+		}
+
+		select {
+		case r := <-l.accepted:
+			if timer != nil {
+				timer.Stop()
+			}
+			return r.fd, r.raddr, r.err
+		case <-timeout:
+			return -1, netip.AddrPort{}, os.ErrDeadlineExceeded
+		case <-l.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return -1, netip.AddrPort{}, ErrClosed
+		case <-wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+		l.mu.Lock()
+	}
 }
 
-func listenTCP(laddr *TCPAddr) (Listener, error) {
-	fd, err := netdev.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TCP)
-	if err != nil {
-		return nil, err
+// acceptBackground runs one netdev.Accept on behalf of the Accept callers.
+// A connection accepted after the listener is closed is closed right away.
+func (l *TCPListener) acceptBackground() {
+	fd, raddr, err := netdev.Accept(l.fd)
+	l.mu.Lock()
+	l.pending = false
+	if l.sock.closed.Load() {
+		l.mu.Unlock()
+		if err == nil {
+			netdev.Close(fd)
+		}
+		return
 	}
+	// Keep one netdev.Accept running as long as someone is waiting
+	if l.waiters > 1 {
+		l.pending = true
+		go l.acceptBackground()
+	}
+	l.mu.Unlock()
+	select {
+	case l.accepted <- acceptResult{fd, raddr, err}:
+		// Close may have drained accepted before the send
+		select {
+		case <-l.done:
+			l.drainAccepted()
+		default:
+		}
+	case <-l.done:
+		if err == nil {
+			netdev.Close(fd)
+		}
+	}
+}
 
-	laddrport := laddr.AddrPort()
-	err = netdev.Bind(fd, laddrport)
-	if err != nil {
-		return nil, err
+// drainAccepted closes the connections accepted but not picked up by
+// Accept before Close.
+func (l *TCPListener) drainAccepted() {
+	for {
+		select {
+		case r := <-l.accepted:
+			if r.err == nil {
+				netdev.Close(r.fd)
+			}
+		default:
+			return
+		}
 	}
+}
 
-	err = netdev.Listen(fd, 5)
+// Close stops listening on the TCP address.
+// Already Accepted connections are not closed.
+func (l *TCPListener) Close() error {
+	err := l.sock.close()
+	if err == ErrClosed {
+		return &OpError{Op: "close", Net: "tcp", Source: nil, Addr: l.laddr, Err: err}
+	}
+	close(l.done)
+	l.drainAccepted()
 	if err != nil {
-		return nil, err
+		err = &OpError{Op: "close", Net: "tcp", Source: nil, Addr: l.laddr, Err: err}
 	}
+	return err
+}
 
-	return &listener{fd: fd, laddr: laddr}, nil
+// Addr returns the listener's network address, a [*TCPAddr].
+// The Addr returned is shared by all invocations of Addr, so
+// do not modify it.
+//
+// TINYGO: For a listener bound to the unspecified address, Addr returns a
+// new TCPAddr with the netdev's current IP address.
+func (l *TCPListener) Addr() Addr {
+	if l.laddr.isWildcard() {
+		if ip, err := netdevAddr(l.fd); err == nil && ip.IsValid() {
+			return &TCPAddr{IP: ip.AsSlice(), Port: l.laddr.Port}
+		}
+	}
+	return l.laddr
 }
 
-// TCPListener is a TCP network listener. Clients should typically
-// use variables of type Listener instead of assuming TCP.
-type TCPListener struct {
-	listener
+// SetDeadline sets the deadline associated with the listener.
+// A zero time value disables the deadline.
+func (l *TCPListener) SetDeadline(t time.Time) error {
+	if l.sock.closed.Load() {
+		return &OpError{Op: "set", Net: "tcp", Source: nil, Addr: l.laddr, Err: ErrClosed}
+	}
+	l.mu.Lock()
+	l.deadline = t
+	close(l.wake)
+	l.wake = make(chan struct{})
+	l.mu.Unlock()
+	return nil
 }
@@ -0,0 +1,287 @@
+// TCPListener accept and deadline tests
+
+package net
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// acceptNetdev is a netdev whose Accept blocks until a connection is
+// queued with connect, or the listening socket is closed.  It has neither
+// acceptDeadliner nor netdevPoller, so TCPListener accepts in the
+// background.
+type acceptNetdev struct {
+	nopNetdev
+	incoming chan int
+	stop     chan struct{}
+
+	mu       sync.Mutex
+	nextFD   int
+	listenFD int
+	pending  int          // Accept calls running
+	accepted map[int]bool // fds returned by Accept
+	closed   map[int]bool
+}
+
+func newAcceptNetdev() *acceptNetdev {
+	return &acceptNetdev{
+		incoming: make(chan int),
+		stop:     make(chan struct{}),
+		nextFD:   1,
+		accepted: make(map[int]bool),
+		closed:   make(map[int]bool),
+	}
+}
+
+func (d *acceptNetdev) Addr() (netip.Addr, error) {
+	return netip.MustParseAddr("10.0.0.2"), nil
+}
+
+func (d *acceptNetdev) Socket(domain int, stype int, protocol int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listenFD = d.nextFD
+	d.nextFD++
+	return d.listenFD, nil
+}
+
+func (d *acceptNetdev) Bind(sockfd int, ip netip.AddrPort) error { return nil }
+func (d *acceptNetdev) Listen(sockfd int, backlog int) error     { return nil }
+
+func (d *acceptNetdev) Accept(sockfd int) (int, netip.AddrPort, error) {
+	d.mu.Lock()
+	d.pending++
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.pending--
+		d.mu.Unlock()
+	}()
+	select {
+	case fd := <-d.incoming:
+		d.mu.Lock()
+		d.accepted[fd] = true
+		d.mu.Unlock()
+		return fd, netip.MustParseAddrPort("10.0.0.3:5000"), nil
+	case <-d.stop:
+		return -1, netip.AddrPort{}, ErrClosed
+	}
+}
+
+func (d *acceptNetdev) Close(sockfd int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed[sockfd] {
+		return ErrClosed
+	}
+	d.closed[sockfd] = true
+	if sockfd == d.listenFD {
+		close(d.stop)
+	}
+	return nil
+}
+
+// connect queues the connection fd for Accept
+func (d *acceptNetdev) connect(t *testing.T, fd int) {
+	select {
+	case d.incoming <- fd:
+	case <-time.After(time.Second):
+		t.Errorf("no Accept running for connection %d", fd)
+	}
+}
+
+func (d *acceptNetdev) isClosed(fd int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed[fd]
+}
+
+func withAcceptNetdev(t *testing.T) (*acceptNetdev, *TCPListener) {
+	d := newAcceptNetdev()
+	old := netdev
+	netdev = d
+	t.Cleanup(func() { netdev = old })
+	ln, err := ListenTCP("tcp", &TCPAddr{Port: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, ln
+}
+
+func TestTCPListenerAccept(t *testing.T) {
+	d, ln := withAcceptNetdev(t)
+	defer ln.Close()
+
+	go d.connect(t, 100)
+	c, err := ln.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.fd != 100 {
+		t.Errorf("accepted fd %d, want 100", c.fd)
+	}
+	if got, want := c.RemoteAddr().String(), "10.0.0.3:5000"; got != want {
+		t.Errorf("RemoteAddr() = %s, want %s", got, want)
+	}
+	if got, want := c.LocalAddr().String(), "10.0.0.2:80"; got != want {
+		t.Errorf("LocalAddr() = %s, want %s", got, want)
+	}
+}
+
+func TestTCPListenerAcceptDeadline(t *testing.T) {
+	d, ln := withAcceptNetdev(t)
+	defer ln.Close()
+
+	ln.SetDeadline(time.Now().Add(20 * time.Millisecond))
+	start := time.Now()
+	if _, err := ln.Accept(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Accept = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	if d := time.Since(start); d < 15*time.Millisecond || d > time.Second {
+		t.Errorf("Accept timed out after %v, want 20ms", d)
+	}
+
+	// The netdev.Accept left running serves the next Accept
+	ln.SetDeadline(time.Time{})
+	go d.connect(t, 100)
+	c, err := ln.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.fd != 100 {
+		t.Errorf("accepted fd %d, want 100", c.fd)
+	}
+}
+
+func TestTCPListenerSetDeadlineWakesAccept(t *testing.T) {
+	_, ln := withAcceptNetdev(t)
+	defer ln.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := ln.Accept()
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	ln.SetDeadline(time.Now())
+	select {
+	case err := <-errc:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("Accept = %v, want %v", err, os.ErrDeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SetDeadline didn't wake Accept")
+	}
+}
+
+func TestTCPListenerCloseWakesAccept(t *testing.T) {
+	_, ln := withAcceptNetdev(t)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := ln.Accept()
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	ln.Close()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Accept = %v, want %v", err, ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't wake Accept")
+	}
+}
+
+func TestTCPListenerCloseClosesAccepted(t *testing.T) {
+	d, ln := withAcceptNetdev(t)
+
+	// Accept gives up, leaving netdev.Accept running; the connection it
+	// accepts is not picked up before Close
+	ln.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := ln.Accept(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Accept = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	d.connect(t, 100)
+	for i := 0; len(ln.accepted) == 0; i++ {
+		if i == 100 {
+			t.Fatal("accepted connection not delivered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ln.Close()
+	if !d.isClosed(100) {
+		t.Errorf("connection accepted before Close not closed")
+	}
+}
+
+func TestTCPListenerConcurrentAcceptsDontLeak(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		d, ln := withAcceptNetdev(t)
+
+		var mu sync.Mutex
+		returned := make(map[int]bool)
+		var wg sync.WaitGroup
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					c, err := ln.AcceptTCP()
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					returned[c.fd] = true
+					mu.Unlock()
+				}
+			}()
+		}
+		// Time out the Accepts while connections come in
+		for fd := 100; fd < 104; fd++ {
+			d.connect(t, fd)
+		}
+		ln.SetDeadline(time.Now())
+		go func() {
+			for fd := 104; ; fd++ {
+				select {
+				case d.incoming <- fd:
+				case <-d.stop:
+					return
+				}
+			}
+		}()
+		time.Sleep(time.Millisecond)
+		ln.Close()
+		wg.Wait()
+
+		// Every connection accepted is either returned or closed, once
+		// the background accepts are done
+		for k := 0; ; k++ {
+			d.mu.Lock()
+			pending := d.pending
+			var leaked []int
+			for fd := range d.accepted {
+				mu.Lock()
+				if !returned[fd] && !d.closed[fd] {
+					leaked = append(leaked, fd)
+				}
+				mu.Unlock()
+			}
+			d.mu.Unlock()
+			if pending == 0 && len(leaked) == 0 {
+				break
+			}
+			if k == 100 {
+				t.Fatalf("#%d: accepted connections %v leaked", i, leaked)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
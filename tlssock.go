@@ -9,17 +9,26 @@
 package net
 
 import (
-	"internal/itoa"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/netip"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	_ "unsafe"
 )
 
 // TLSAddr represents the address of a TLS end point.
 type TLSAddr struct {
 	Host string
 	Port int
+
+	// TINYGO: IP is the address Host resolved to, if known
+	IP IP
 }
 
 func (a *TLSAddr) Network() string { return "tls" }
@@ -28,7 +37,11 @@ func (a *TLSAddr) String() string {
 	if a == nil {
 		return "<nil>"
 	}
-	return JoinHostPort(a.Host, itoa.Itoa(a.Port))
+	host := a.Host
+	if a.IP != nil {
+		host = a.IP.String()
+	}
+	return JoinHostPort(host, netItoa(a.Port))
 }
 
 // A TLSConn represents a secured connection.
@@ -40,47 +53,430 @@ type TLSConn struct {
 	raddr         *TLSAddr
 	readDeadline  time.Time
 	writeDeadline time.Time
+	sock          *socket
+
+	serverName string
+	soft       tlsClientConn // software TLS session, if not offloaded
+	recordSize int           // software TLS record size limit, or 0
+
+	hsMu       sync.Mutex  // serializes handshakes, guards hsErr
+	handshaked atomic.Bool // handshake completed
+	hsErr      error       // handshake error, sticky
+}
+
+// tlsHandshaker is an optional netdever extension for TLS offload devices
+// which connect the _IPPROTO_TLS socket in Connect but defer the TLS
+// handshake.  TLSHandshake runs the handshake, giving up at deadline (a
+// zero deadline means no deadline).  Without the extension, Connect
+// includes the handshake.
+type tlsHandshaker interface {
+	TLSHandshake(sockfd int, deadline time.Time) error
+}
+
+// tlsStater is an optional netdever extension for TLS offload devices able
+// to report the parameters negotiated on a _IPPROTO_TLS socket after the
+// handshake.  Fields the device doesn't know are left zero.
+type tlsStater interface {
+	TLSState(sockfd int) (TLSConnectionState, error)
+}
+
+// TLSConnectionState records basic TLS details about a TLSConn.  It is the
+// subset of crypto/tls.ConnectionState a netdev can report; fields the
+// netdev doesn't report are zero.
+type TLSConnectionState struct {
+	// Version is the TLS version used by the connection, using the
+	// crypto/tls VersionTLS constants.
+	Version uint16
+
+	// HandshakeComplete is true if the handshake has concluded.
+	HandshakeComplete bool
+
+	// CipherSuite is the cipher suite negotiated for the connection,
+	// using the crypto/tls cipher suite IDs.
+	CipherSuite uint16
+
+	// NegotiatedProtocol is the application protocol negotiated with
+	// ALPN.
+	NegotiatedProtocol string
+
+	// ServerName is the value of the Server Name Indication extension
+	// sent by the client.
+	ServerName string
+
+	// PeerCertificates are the DER encoded certificates sent by the
+	// peer, leaf first.
+	PeerCertificates [][]byte
+
+	// Offloaded is true if TLS runs on the netdev, and false if it runs
+	// in software over a TCPConn.
+	Offloaded bool
+}
+
+// tlsClient is the software TLS client, installed with useTLSClient.  It
+// runs a client handshake over conn, giving up when ctx is done, and
+// returns the secured connection.  DialTLS falls back to it when the
+// netdev has no TLS offload.
+//
+// The net package can't import crypto/tls, so the client is installed by
+// net/http on init.  Without net/http linked in, tlsClient is nil and
+// there is no fallback.
+var tlsClient func(ctx context.Context, conn Conn, config *TLSConfig) (tlsClientConn, error)
+
+// tlsClientConn is a connection secured by the software TLS client
+type tlsClientConn interface {
+	Conn
+	ConnectionState() TLSConnectionState
 }
 
+// (useTLSClient is go:linkname'd from the net/http package, which has
+// crypto/tls at hand)
+//
+//go:linkname useTLSClient
+func useTLSClient(client func(ctx context.Context, conn Conn, config *TLSConfig) (tlsClientConn, error)) {
+	tlsClient = client
+}
+
+// TLSConfig configures the TLS offload of the netdev for DialTLSConfig and
+// ListenTLS.  It holds the subset of crypto/tls.Config a netdev can be
+// given; crypto/tls converts its Config to a TLSConfig.  A zero TLSConfig
+// uses the netdev's defaults, like DialTLS.
+type TLSConfig struct {
+	// ServerName is used for SNI and to verify the server certificate.
+	// If empty, the host of the dialed address is used.
+	ServerName string
+
+	// RootCAs are the DER encoded certificates of the authorities to
+	// verify the server certificate with.  If nil, the netdev uses its
+	// own root CAs.
+	RootCAs [][]byte
+
+	// Certificate is the DER encoded certificate chain, leaf first,
+	// presented to the peer: the server certificate for ListenTLS, or
+	// the client certificate for servers asking for one (mutual TLS).
+	Certificate [][]byte
+
+	// PrivateKey is the PKCS #8 DER encoded private key of Certificate.
+	PrivateKey []byte
+
+	// MinVersion and MaxVersion bound the TLS version negotiated, using
+	// the crypto/tls VersionTLS constants.  Zero means the netdev's
+	// default.
+	MinVersion uint16
+	MaxVersion uint16
+
+	// InsecureSkipVerify disables verification of the server
+	// certificate chain and host name.  For testing only.
+	InsecureSkipVerify bool
+
+	// NextProtos is the list of ALPN protocols, in order of preference.
+	NextProtos []string
+
+	// ClientCAs are the DER encoded certificates of the authorities a
+	// ListenTLS server verifies client certificates with.  If nil, the
+	// netdev uses its own root CAs.
+	ClientCAs [][]byte
+
+	// ClientAuth is the ListenTLS server's policy for client
+	// certificates, using the crypto/tls ClientAuthType values.  Zero
+	// means no client certificate is asked for.
+	ClientAuth int
+
+	// DisableFallback makes DialTLSConfig fail when the netdev has no TLS
+	// offload, instead of falling back to the software TLS client.
+	DisableFallback bool
+
+	// RecordSizeLimit, if not zero, limits the plaintext size of the
+	// records sent by the software TLS client, by writing at most
+	// RecordSizeLimit bytes at a time.  It only limits outgoing records
+	// and doesn't save receive memory: the max_fragment_length and
+	// record_size_limit extensions aren't negotiated, so the peer may
+	// still send full-size records, and crypto/tls allocates its receive
+	// buffers for them.  It doesn't apply to the TLS offload.
+	RecordSizeLimit int
+}
+
+// set sets the options of the TLS socket fd from the config, skipping
+// options left at their zero value.
+func (config *TLSConfig) set(fd int) error {
+	if config == nil {
+		return nil
+	}
+	opts := []struct {
+		name  string
+		opt   int
+		isSet bool
+		value any
+	}{
+		{"ServerName", _TLS_SERVER_NAME, config.ServerName != "", config.ServerName},
+		{"RootCAs", _TLS_ROOT_CAS, config.RootCAs != nil, config.RootCAs},
+		{"Certificate", _TLS_CERTIFICATE, config.Certificate != nil, config.Certificate},
+		{"PrivateKey", _TLS_PRIVATE_KEY, config.PrivateKey != nil, config.PrivateKey},
+		{"MinVersion", _TLS_MIN_VERSION, config.MinVersion != 0, config.MinVersion},
+		{"MaxVersion", _TLS_MAX_VERSION, config.MaxVersion != 0, config.MaxVersion},
+		{"InsecureSkipVerify", _TLS_INSECURE_SKIP_VERIFY, config.InsecureSkipVerify, true},
+		{"NextProtos", _TLS_ALPN, config.NextProtos != nil, config.NextProtos},
+		{"ClientCAs", _TLS_CLIENT_CAS, config.ClientCAs != nil, config.ClientCAs},
+		{"ClientAuth", _TLS_CLIENT_AUTH, config.ClientAuth != 0, config.ClientAuth},
+	}
+	for _, o := range opts {
+		if !o.isSet {
+			continue
+		}
+		if err := netdev.SetSockOpt(fd, _SOL_TLS, o.opt, o.value); err != nil {
+			return fmt.Errorf("TLSConfig.%s: %w", o.name, err)
+		}
+	}
+	return nil
+}
+
+// DialTLS connects to the address using the TLS offload of the netdev.  If
+// the address has no port, or port 0, 443 is used.
+//
+// If the netdev has no TLS offload, TLS runs in software, with crypto/tls
+// over a TCPConn, but only if the program imports net/http, which
+// installs the software TLS client; the net package can't import
+// crypto/tls itself.  Without net/http, DialTLS fails on such netdevs.
+//
+// DialTLS is also available as the "tls" network of [Dial] and
+// [Dialer.DialContext].
 func DialTLS(addr string) (*TLSConn, error) {
+	return dialTLS(context.Background(), defaultSocketTrace.Load(), nil, addr, nil)
+}
+
+// DialTLSConfig is like DialTLS, configuring the TLS offload with config
+// before connecting.  If the netdev can't honor a setting of config,
+// DialTLSConfig fails, naming the setting in the error.
+func DialTLSConfig(addr string, config *TLSConfig) (*TLSConn, error) {
+	return dialTLS(context.Background(), defaultSocketTrace.Load(), nil, addr, config)
+}
 
+// splitTLSAddr splits addr into host and port, defaulting to port 443
+func splitTLSAddr(addr string) (string, int, error) {
 	host, sport, err := SplitHostPort(addr)
 	if err != nil {
+		if ae, ok := err.(*AddrError); !ok || ae.Err != "missing port in address" {
+			return "", 0, err
+		}
+		host, sport = addr, "0"
+	}
+	if sport == "https" {
+		sport = "443"
+	}
+	port, err := strconv.Atoi(sport)
+	if err != nil || port < 0 || port > 0xffff {
+		return "", 0, &AddrError{Err: "invalid port", Addr: addr}
+	}
+	if port == 0 {
+		port = 443
+	}
+	return host, port, nil
+}
+
+// tlsUnsupported reports whether err, from netdev.Socket, means the netdev
+// has no TLS offload.
+func tlsUnsupported(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported) || errors.Is(err, syscall.EPROTONOSUPPORT)
+}
+
+// dialTLS connects to addr with TLS, giving up when ctx is done.  ctrl
+// runs on the socket before connecting.
+func dialTLS(ctx context.Context, trace *SocketTrace, ctrl controlFunc, addr string, config *TLSConfig) (*TLSConn, error) {
+
+	host, port, err := splitTLSAddr(addr)
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: "tls", Source: nil, Addr: nil, Err: err}
+	}
+
+	// The netdev resolves the host in Connect, so RemoteAddr only has
+	// the IP if the host is a literal IP.
+	raddr := &TLSAddr{Host: host, Port: port}
+	var ip netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		ip = addr
+		raddr.IP = IP(addr.AsSlice())
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, &OpError{Op: "dial", Net: "tls", Source: nil, Addr: raddr, Err: err}
+	}
+
+	start := time.Now()
+
+	fd, err := netdev.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TLS)
+	trace.socketCreated(fd, "tls", err)
+	if err != nil {
+		if tlsClient != nil && tlsUnsupported(err) &&
+			(config == nil || !config.DisableFallback) {
+			// No TLS offload; run TLS in software
+			return dialSoftTLS(ctx, trace, ctrl, raddr, config)
+		}
+		countDial(start, err)
+		return nil, err
+	}
+	// Registered now so a hung connect shows in OpenSockets.  The local
+	// address is only known once connected.
+	sock := newSocket(fd, "TLSConn", "tls", nil, raddr, trace)
+	if err := runControl(ctrl, fd, raddr.String()); err != nil {
+		sock.close()
+		countDial(start, err)
 		return nil, err
 	}
+	if err := config.set(fd); err != nil {
+		sock.close()
+		countDial(start, err)
+		return nil, &OpError{Op: "dial", Net: "tls", Source: nil, Addr: raddr, Err: err}
+	}
 
-	port, err := strconv.Atoi(sport)
+	// Interrupt the connect by closing the socket if ctx is done first
+	stop := context.AfterFunc(ctx, func() { sock.close() })
+	trace.tlsConnectStart(fd, raddr)
+	err = netdev.Connect(fd, host, netip.AddrPortFrom(ip, uint16(port)))
+	if !stop() {
+		err = ctx.Err()
+	}
+	trace.tlsConnectDone(fd, raddr, err)
+	if err != nil {
+		sock.close()
+		countDial(start, err)
+		return nil, &OpError{Op: "dial", Net: "tls", Source: nil, Addr: raddr, Err: err}
+	}
+	countDial(start, nil)
+
+	laddr := localTLSAddr(fd)
+	sock.setLocalAddr(laddr)
+	c := &TLSConn{
+		fd:         fd,
+		net:        "tls",
+		laddr:      laddr,
+		raddr:      raddr,
+		sock:       sock,
+		serverName: host,
+	}
+	if config != nil && config.ServerName != "" {
+		c.serverName = config.ServerName
+	}
+	if _, ok := netdevExt[tlsHandshaker](fd); !ok {
+		// The handshake was part of Connect
+		c.handshaked.Store(true)
+	}
+	return c, nil
+}
+
+// dialSoftTLS connects to raddr with the software TLS client over a
+// TCPConn.  The host is resolved here, as there is no netdev TLS Connect
+// to resolve it, so raddr gets the IP.
+func dialSoftTLS(ctx context.Context, trace *SocketTrace, ctrl controlFunc, raddr *TLSAddr, config *TLSConfig) (*TLSConn, error) {
+	var cfg TLSConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = raddr.Host
+	}
+
+	taddr, err := resolveTCPAddr(trace, "tcp", JoinHostPort(raddr.Host, netItoa(raddr.Port)))
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: "tls", Source: nil, Addr: raddr, Err: err}
+	}
+	raddr.IP = taddr.IP
+
+	tc, err := dialTCP(trace, ctrl, "tcp", nil, taddr)
 	if err != nil {
 		return nil, err
 	}
 
-	if port == 0 {
-		port = 443
+	trace.tlsConnectStart(tc.fd, raddr)
+	soft, err := tlsClient(ctx, tc, &cfg)
+	trace.tlsConnectDone(tc.fd, raddr, err)
+	if err != nil {
+		tc.Close()
+		return nil, &OpError{Op: "dial", Net: "tls", Source: nil, Addr: raddr, Err: err}
 	}
 
-	fd, err := netdev.Socket(_AF_INET, _SOCK_STREAM, _IPPROTO_TLS)
+	c := &TLSConn{
+		fd:         tc.fd,
+		net:        "tls",
+		laddr:      localTLSAddr(tc.fd),
+		raddr:      raddr,
+		sock:       tc.sock,
+		serverName: cfg.ServerName,
+		soft:       soft,
+		recordSize: cfg.RecordSizeLimit,
+	}
+	c.handshaked.Store(true)
+	return c, nil
+}
+
+// sockNamer is an optional netdever extension for devices able to report
+// the local address of a connected socket, like getsockname(2).
+type sockNamer interface {
+	GetSockName(sockfd int) (netip.AddrPort, error)
+}
+
+// localTLSAddr returns the local address of the connected socket fd.
+// Without sockNamer, the port is unknown and left 0; if the netdev has no
+// address either, the IP is the unspecified address.  It never returns
+// nil, so LocalAddr never returns a non-nil Addr holding a nil *TLSAddr.
+func localTLSAddr(fd int) *TLSAddr {
+	if sn, ok := netdevExt[sockNamer](fd); ok {
+		if addr, err := sn.GetSockName(fd); err == nil {
+			return tlsAddrFromAddrPort(addr)
+		}
+	}
+	ip, err := netdevAddr(fd)
+	if err != nil || !ip.IsValid() {
+		ip = netip.IPv4Unspecified()
+	}
+	return tlsAddrFromAddrPort(netip.AddrPortFrom(ip, 0))
+}
+
+// dialTLS dials the "tls" network for DialContext, within d's timeout and
+// deadline, with d's TLSConfig and keep-alive settings.  The dial includes
+// the TLS handshake.
+func (d *Dialer) dialTLS(ctx context.Context, trace *SocketTrace, address string) (*TLSConn, error) {
+	if deadline := d.deadline(ctx, time.Now()); !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	c, err := dialTLS(ctx, trace, d.control(ctx, "tls"), address, d.TLSConfig)
 	if err != nil {
 		return nil, err
 	}
-	addrport := netip.AddrPortFrom(netip.Addr{}, uint16(port))
-	if err = netdev.Connect(fd, host, addrport); err != nil {
-		netdev.Close(fd)
+	if err := c.HandshakeContext(ctx); err != nil {
+		c.Close()
 		return nil, err
 	}
 
-	return &TLSConn{
-		fd:    fd,
-		net:   "tls",
-		raddr: &TLSAddr{host, port},
-	}, nil
+	// TINYGO: Keep-alives are only configured if asked for, to spare
+	// netdevs the round trips.  Errors are ignored, as upstream does.
+	config := d.KeepAliveConfig
+	if !config.Enable && d.KeepAlive > 0 {
+		config = KeepAliveConfig{Enable: true, Idle: d.KeepAlive}
+	}
+	if config.Enable {
+		c.SetKeepAliveConfig(config)
+	}
+	return c, nil
 }
 
 func (c *TLSConn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	if c.soft != nil {
+		return c.soft.Read(b)
+	}
+	if err := waitReadable(c.fd, c.readDeadline); err != nil {
+		return 0, &OpError{Op: "read", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
 	n, err := netdev.Recv(c.fd, b, 0, c.readDeadline)
 	// Turn the -1 socket error into 0 and let err speak for error
 	if n < 0 {
 		n = 0
 	}
+	c.sock.countRecv(n, err)
 	if err != nil && err != io.EOF {
 		err = &OpError{Op: "read", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
 	}
@@ -88,19 +484,54 @@ func (c *TLSConn) Read(b []byte) (int, error) {
 }
 
 func (c *TLSConn) Write(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	if c.soft != nil {
+		return c.writeSoft(b)
+	}
 	n, err := netdev.Send(c.fd, b, 0, c.writeDeadline)
 	// Turn the -1 socket error into 0 and let err speak for error
 	if n < 0 {
 		n = 0
 	}
+	c.sock.countSend(n, err)
 	if err != nil {
 		err = &OpError{Op: "write", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
 	}
 	return n, err
 }
 
+// writeSoft writes b with the software TLS client, in chunks of at most
+// the record size limit.
+func (c *TLSConn) writeSoft(b []byte) (int, error) {
+	if c.recordSize <= 0 {
+		return c.soft.Write(b)
+	}
+	var n int
+	for len(b) > 0 {
+		chunk := min(len(b), c.recordSize)
+		nn, err := c.soft.Write(b[:chunk])
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		b = b[chunk:]
+	}
+	return n, nil
+}
+
 func (c *TLSConn) Close() error {
-	return netdev.Close(c.fd)
+	if c.soft != nil {
+		// Sends close_notify, then closes the TCPConn
+		return c.soft.Close()
+	}
+	return c.sock.close()
+}
+
+// Stats returns the traffic counters of the connection.
+func (c *TLSConn) Stats() ConnStats {
+	return c.sock.connStats()
 }
 
 func (c *TLSConn) LocalAddr() Addr {
@@ -111,19 +542,81 @@ func (c *TLSConn) RemoteAddr() Addr {
 	return c.raddr
 }
 
+// SetLinger sets the behavior of Close on a connection which still
+// has data waiting to be sent or to be acknowledged.  See
+// [TCPConn.SetLinger].
+func (c *TLSConn) SetLinger(sec int) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_LINGER, sec)
+}
+
+// SetKeepAlive sets whether the netdev should send keep-alive messages
+// on the connection.
+func (c *TLSConn) SetKeepAlive(keepalive bool) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_KEEPALIVE, keepalive)
+}
+
+// SetKeepAlivePeriod sets the duration the connection needs to remain
+// idle before the netdev starts sending keepalive probes.
+func (c *TLSConn) SetKeepAlivePeriod(d time.Duration) error {
+	// Units are 1/2 seconds, as for TCPConn
+	return c.setSockOpt(_SOL_TCP, _TCP_KEEPINTVL, 2*d.Seconds())
+}
+
+// SetKeepAliveConfig configures keep-alive messages sent by the netdev.
+func (c *TLSConn) SetKeepAliveConfig(config KeepAliveConfig) error {
+	return setKeepAliveConfig(c.setSockOpt, config)
+}
+
+// SetNoDelay controls whether the netdev should delay packet
+// transmission in hopes of sending fewer packets (Nagle's algorithm).
+func (c *TLSConn) SetNoDelay(noDelay bool) error {
+	return c.setSockOpt(_SOL_TCP, _TCP_NODELAY, noDelay)
+}
+
+// SetReadBuffer sets the size of the netdev's receive buffer associated
+// with the connection.
+func (c *TLSConn) SetReadBuffer(bytes int) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_RCVBUF, bytes)
+}
+
+// SetWriteBuffer sets the size of the netdev's transmit buffer
+// associated with the connection.
+func (c *TLSConn) SetWriteBuffer(bytes int) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_SNDBUF, bytes)
+}
+
+func (c *TLSConn) setSockOpt(level, opt int, value any) error {
+	if c.sock.closed.Load() {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	if err := netdev.SetSockOpt(c.fd, level, opt, value); err != nil {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return nil
+}
+
 func (c *TLSConn) SetDeadline(t time.Time) error {
 	c.readDeadline = t
 	c.writeDeadline = t
+	if c.soft != nil {
+		return c.soft.SetDeadline(t)
+	}
 	return nil
 }
 
 func (c *TLSConn) SetReadDeadline(t time.Time) error {
 	c.readDeadline = t
+	if c.soft != nil {
+		return c.soft.SetReadDeadline(t)
+	}
 	return nil
 }
 
 func (c *TLSConn) SetWriteDeadline(t time.Time) error {
 	c.writeDeadline = t
+	if c.soft != nil {
+		return c.soft.SetWriteDeadline(t)
+	}
 	return nil
 }
 
@@ -135,7 +628,189 @@ func (c *TLSConn) SetWriteDeadline(t time.Time) error {
 //
 // For control over canceling or setting a timeout on a handshake, use
 // HandshakeContext or the Dialer's DialContext method instead.
+//
+// TINYGO: The handshake runs on the netdev.  Unless the netdev defers it,
+// it is done as part of the dial and Handshake only confirms it.
 func (c *TLSConn) Handshake() error {
-	panic("TLSConn.Handshake() not implemented")
+	return c.HandshakeContext(context.Background())
+}
+
+// HandshakeContext runs the client or server handshake
+// protocol if it has not yet been run.
+//
+// The provided Context must be non-nil. If the context is canceled before
+// the handshake is complete, the handshake is interrupted and an error is returned.
+// Once the handshake has completed, cancellation of the context will not affect the
+// connection.
+//
+// Most uses of this package need not call HandshakeContext explicitly: the
+// first Read or Write will call it automatically.
+func (c *TLSConn) HandshakeContext(ctx context.Context) error {
+	if c.handshaked.Load() {
+		return nil
+	}
+
+	c.hsMu.Lock()
+	defer c.hsMu.Unlock()
+
+	if c.hsErr != nil {
+		return c.hsErr
+	}
+	if c.handshaked.Load() {
+		return nil
+	}
+	if c.sock.closed.Load() {
+		return &OpError{Op: "handshake", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	if err := ctx.Err(); err != nil {
+		return &OpError{Op: "handshake", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+
+	// The handshake both reads and writes, so the earliest of the
+	// deadlines applies
+	deadline, _ := ctx.Deadline()
+	for _, d := range []time.Time{c.readDeadline, c.writeDeadline} {
+		if !d.IsZero() && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+
+	// Interrupt the handshake by closing the conn if ctx is done first,
+	// like crypto/tls does
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	hs, _ := netdevExt[tlsHandshaker](c.fd)
+	err := hs.TLSHandshake(c.fd, deadline)
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		c.hsErr = &OpError{Op: "handshake", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+		return c.hsErr
+	}
+	c.handshaked.Store(true)
 	return nil
 }
+
+// ConnectionState returns basic TLS details about the connection.
+//
+// TINYGO: With TLS offload, the details come from the netdev, if it can
+// report them.  Otherwise only HandshakeComplete, ServerName and Offloaded
+// are set.
+func (c *TLSConn) ConnectionState() TLSConnectionState {
+	if c.soft != nil {
+		return c.soft.ConnectionState()
+	}
+	state := TLSConnectionState{
+		HandshakeComplete: c.handshaked.Load(),
+		ServerName:        c.serverName,
+		Offloaded:         true,
+	}
+	if !state.HandshakeComplete || c.sock.closed.Load() {
+		return state
+	}
+	if s, ok := netdevExt[tlsStater](c.fd); ok {
+		if ds, err := s.TLSState(c.fd); err == nil {
+			ds.HandshakeComplete = true
+			ds.Offloaded = true
+			if ds.ServerName == "" {
+				ds.ServerName = c.serverName
+			}
+			state = ds
+		}
+	}
+	return state
+}
+
+// TLSListener is a TLS network listener using the TLS server offload of
+// the netdev.  Its connections are *TLSConn.
+type TLSListener struct {
+	l *TCPListener
+}
+
+// ListenTLS announces on the local network address, using the TLS server
+// offload of the netdev configured with config.  Config must at least
+// hold the server Certificate and PrivateKey, unless the netdev has its
+// own.
+//
+// The network must be "tcp" or "tcp4".  ListenTLS fails if the netdev has
+// no TLS server offload or can't honor a setting of config; crypto/tls
+// can then run over a TCPListener instead.
+func ListenTLS(network, address string, config *TLSConfig) (*TLSListener, error) {
+	laddr, err := ResolveTCPAddr(network, address)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: err}
+	}
+	ctrl := func(fd int, address string) error {
+		return config.set(fd)
+	}
+	l, err := listenStream(defaultSocketTrace.Load(), ctrl, _IPPROTO_TLS, "TLSListener", network, laddr, defaultBacklog)
+	if err != nil {
+		return nil, err
+	}
+	return &TLSListener{l: l}, nil
+}
+
+func tlsAddrFromAddrPort(addr netip.AddrPort) *TLSAddr {
+	ip := IP(addr.Addr().AsSlice())
+	return &TLSAddr{Host: ip.String(), Port: int(addr.Port()), IP: ip}
+}
+
+// AcceptTLS accepts the next incoming call and returns the new
+// connection.  The TLS handshake is done by the netdev, before AcceptTLS
+// returns or on the first Read or Write.
+func (l *TLSListener) AcceptTLS() (*TLSConn, error) {
+	if l.l.sock.closed.Load() {
+		return nil, &OpError{Op: "accept", Net: "tls", Source: nil, Addr: l.Addr(), Err: ErrClosed}
+	}
+	fd, raddr, err := l.l.accept()
+	var tlsraddr *TLSAddr
+	if err == nil {
+		tlsraddr = tlsAddrFromAddrPort(raddr)
+	}
+	l.l.trace.accept(l.l.fd, fd, tlsraddr, err)
+	if err != nil {
+		return nil, &OpError{Op: "accept", Net: "tls", Source: nil, Addr: l.Addr(), Err: err}
+	}
+
+	laddr := l.Addr().(*TCPAddr)
+	tlsladdr := &TLSAddr{Host: laddr.IP.String(), Port: laddr.Port, IP: laddr.IP}
+	c := &TLSConn{
+		fd:    fd,
+		net:   "tls",
+		laddr: tlsladdr,
+		raddr: tlsraddr,
+		sock:  newSocket(fd, "TLSConn", "tls", tlsladdr, tlsraddr, l.l.trace),
+	}
+	if _, ok := netdevExt[tlsHandshaker](fd); !ok {
+		// The handshake was part of Accept
+		c.handshaked.Store(true)
+	}
+	return c, nil
+}
+
+// Accept implements the Accept method in the [Listener] interface; it
+// waits for the next call and returns a generic [Conn].
+func (l *TLSListener) Accept() (Conn, error) {
+	c, err := l.AcceptTLS()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close stops listening on the TLS address.
+// Already Accepted connections are not closed.
+func (l *TLSListener) Close() error {
+	return l.l.Close()
+}
+
+// Addr returns the listener's network address, a [*TCPAddr].
+func (l *TLSListener) Addr() Addr {
+	return l.l.Addr()
+}
+
+// SetDeadline sets the deadline associated with the listener.
+// A zero time value disables the deadline.
+func (l *TLSListener) SetDeadline(t time.Time) error {
+	return l.l.SetDeadline(t)
+}
@@ -0,0 +1,21 @@
+// TLSConn address tests
+
+package net
+
+import "testing"
+
+func TestTLSConnLocalAddrUnknown(t *testing.T) {
+	// nopNetdev knows no address for the socket
+	oldNetdev := netdev
+	netdev = &nopNetdev{}
+	t.Cleanup(func() { netdev = oldNetdev })
+
+	c := &TLSConn{fd: 7, laddr: localTLSAddr(7)}
+	addr := c.LocalAddr()
+	if addr == nil {
+		t.Fatal("LocalAddr() = nil")
+	}
+	if addr.Network() != "tls" || addr.String() != "0.0.0.0:0" {
+		t.Errorf("LocalAddr() = %s/%s, want tls/0.0.0.0:0", addr.Network(), addr)
+	}
+}
@@ -0,0 +1,185 @@
+// Socket tracing
+
+package net
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SocketTrace is a set of hooks to run at various stages of netdev socket
+// operations, much like httptrace.ClientTrace does for HTTP requests.  Any
+// particular hook may be nil.  Functions may be called concurrently from
+// different goroutines and some may be called after the dial or accept
+// returns, as the trace stays attached to the connection until Close.
+//
+// A SocketTrace is carried by a context, see WithSocketTrace.  It is
+// picked up by Dialer.DialContext and ListenConfig.Listen, which pass it on
+// to the connections they create.  DialTCP, DialUDP, DialTLS and the other
+// functions taking no context use the trace set with
+// SetDefaultSocketTrace, as do contexts carrying no trace.
+type SocketTrace struct {
+	// ResolveStart is called when starting to resolve a host name with
+	// the netdev resolver.
+	ResolveStart func(host string)
+
+	// ResolveDone is called when resolving a host name is done.
+	ResolveDone func(host string, ip IP, err error)
+
+	// SocketCreated is called when netdev.Socket returns.  On error, fd
+	// is -1.
+	SocketCreated func(fd int, network string, err error)
+
+	// ConnectStart is called when starting to connect the socket to
+	// raddr.
+	ConnectStart func(fd int, network string, raddr Addr)
+
+	// ConnectDone is called when the connect is done.
+	ConnectDone func(fd int, network string, raddr Addr, err error)
+
+	// TLSConnectStart is called when starting to connect a socket using
+	// the netdev's TLS offload.  The connect includes the TLS handshake,
+	// which runs on the device.
+	TLSConnectStart func(fd int, raddr Addr)
+
+	// TLSConnectDone is called when the TLS offload connect is done.
+	TLSConnectDone func(fd int, raddr Addr, err error)
+
+	// Bind is called when binding the socket to laddr is done.
+	Bind func(fd int, laddr Addr, err error)
+
+	// Listen is called when putting the socket in listening mode is done.
+	Listen func(fd int, backlog int, err error)
+
+	// Accept is called when a listening socket accepts a connection.
+	// On error, fd is -1 and raddr is nil.
+	Accept func(lfd int, fd int, raddr Addr, err error)
+
+	// FirstByteRead is called when the first byte is read from the
+	// connection.
+	FirstByteRead func(fd int)
+
+	// Close is called when the socket is closed.
+	Close func(fd int, err error)
+}
+
+// unique type to prevent assignment.
+type socketTraceContextKey struct{}
+
+// WithSocketTrace returns a new context based on the provided parent ctx.
+// Sockets created with the returned context will use the provided trace
+// hooks.  A trace already in ctx is replaced.
+func WithSocketTrace(ctx context.Context, trace *SocketTrace) context.Context {
+	if trace == nil {
+		panic("nil trace")
+	}
+	return context.WithValue(ctx, socketTraceContextKey{}, trace)
+}
+
+// ContextSocketTrace returns the [SocketTrace] associated with the
+// provided context. If none, it returns nil.
+func ContextSocketTrace(ctx context.Context) *SocketTrace {
+	trace, _ := ctx.Value(socketTraceContextKey{}).(*SocketTrace)
+	return trace
+}
+
+// defaultSocketTrace is set by SetDefaultSocketTrace
+var defaultSocketTrace atomic.Pointer[SocketTrace]
+
+// SetDefaultSocketTrace sets the trace of the sockets created without a
+// trace in their context, or without a context: those of DialTCP,
+// DialUDP, DialTLS, DialIP, ListenTCP, ListenIP and ListenTLS.  A nil
+// trace removes the default trace.  Sockets keep the trace they were
+// created with.
+func SetDefaultSocketTrace(trace *SocketTrace) {
+	defaultSocketTrace.Store(trace)
+}
+
+// socketTrace returns the trace in ctx, or else the default trace
+func socketTrace(ctx context.Context) *SocketTrace {
+	if trace := ContextSocketTrace(ctx); trace != nil {
+		return trace
+	}
+	return defaultSocketTrace.Load()
+}
+
+// The hook wrappers below are nil-safe for both the trace and the hook, so
+// the untraced path costs a single nil check.
+
+func (t *SocketTrace) resolveStart(host string) {
+	if t != nil && t.ResolveStart != nil {
+		t.ResolveStart(host)
+	}
+}
+
+func (t *SocketTrace) resolveDone(host string, ip IP, err error) {
+	if t != nil && t.ResolveDone != nil {
+		t.ResolveDone(host, ip, err)
+	}
+}
+
+func (t *SocketTrace) socketCreated(fd int, network string, err error) {
+	if t != nil && t.SocketCreated != nil {
+		if err != nil {
+			fd = -1
+		}
+		t.SocketCreated(fd, network, err)
+	}
+}
+
+func (t *SocketTrace) connectStart(fd int, network string, raddr Addr) {
+	if t != nil && t.ConnectStart != nil {
+		t.ConnectStart(fd, network, raddr)
+	}
+}
+
+func (t *SocketTrace) connectDone(fd int, network string, raddr Addr, err error) {
+	if t != nil && t.ConnectDone != nil {
+		t.ConnectDone(fd, network, raddr, err)
+	}
+}
+
+func (t *SocketTrace) tlsConnectStart(fd int, raddr Addr) {
+	if t != nil && t.TLSConnectStart != nil {
+		t.TLSConnectStart(fd, raddr)
+	}
+}
+
+func (t *SocketTrace) tlsConnectDone(fd int, raddr Addr, err error) {
+	if t != nil && t.TLSConnectDone != nil {
+		t.TLSConnectDone(fd, raddr, err)
+	}
+}
+
+func (t *SocketTrace) bind(fd int, laddr Addr, err error) {
+	if t != nil && t.Bind != nil {
+		t.Bind(fd, laddr, err)
+	}
+}
+
+func (t *SocketTrace) listen(fd int, backlog int, err error) {
+	if t != nil && t.Listen != nil {
+		t.Listen(fd, backlog, err)
+	}
+}
+
+func (t *SocketTrace) accept(lfd int, fd int, raddr Addr, err error) {
+	if t != nil && t.Accept != nil {
+		if err != nil {
+			fd, raddr = -1, nil
+		}
+		t.Accept(lfd, fd, raddr, err)
+	}
+}
+
+func (t *SocketTrace) firstByteRead(fd int) {
+	if t != nil && t.FirstByteRead != nil {
+		t.FirstByteRead(fd)
+	}
+}
+
+func (t *SocketTrace) close(fd int, err error) {
+	if t != nil && t.Close != nil {
+		t.Close(fd, err)
+	}
+}
@@ -0,0 +1,58 @@
+// Socket trace tests
+
+package net
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+// resolvingNetdev is a rawNetdev resolving IP literals
+type resolvingNetdev struct {
+	rawNetdev
+}
+
+func (d *resolvingNetdev) GetHostByName(name string) (netip.Addr, error) {
+	return netip.ParseAddr(name)
+}
+
+func TestDefaultSocketTrace(t *testing.T) {
+	oldNetdev := netdev
+	netdev = &resolvingNetdev{}
+	t.Cleanup(func() {
+		netdev = oldNetdev
+		SetDefaultSocketTrace(nil)
+	})
+
+	var events []string
+	record := func(name string) *SocketTrace {
+		return &SocketTrace{
+			SocketCreated: func(fd int, network string, err error) { events = append(events, name+" socket") },
+			ConnectDone:   func(fd int, network string, raddr Addr, err error) { events = append(events, name+" connect") },
+		}
+	}
+	SetDefaultSocketTrace(record("default"))
+
+	// DialTCP has no context, so runs with the default trace
+	raddr := &TCPAddr{IP: IP{192, 0, 2, 1}, Port: 80}
+	if c, err := DialTCP("tcp", nil, raddr); err == nil {
+		c.Close()
+	}
+	// A trace in the context takes precedence
+	ctx := WithSocketTrace(context.Background(), record("context"))
+	if c, err := (&Dialer{}).DialContext(ctx, "tcp", raddr.String()); err == nil {
+		c.Close()
+	}
+
+	want := []string{"default socket", "default connect", "context socket", "context connect"}
+	if len(events) != len(want) {
+		t.Fatalf("events %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events %q, want %q", events, want)
+			break
+		}
+	}
+}
@@ -81,6 +81,10 @@ func (a *UDPAddr) opAddr() Addr {
 // See func [Dial] for a description of the network and address
 // parameters.
 func ResolveUDPAddr(network, address string) (*UDPAddr, error) {
+	return resolveUDPAddr(nil, network, address)
+}
+
+func resolveUDPAddr(trace *SocketTrace, network, address string) (*UDPAddr, error) {
 
 	switch network {
 	case "udp", "udp4":
@@ -105,7 +109,7 @@ func ResolveUDPAddr(network, address string) (*UDPAddr, error) {
 		return &UDPAddr{Port: port}, nil
 	}
 
-	ip, err := netdev.GetHostByName(host)
+	ip, err := lookupHost(trace, host)
 	if err != nil {
 		return nil, fmt.Errorf("Lookup of host name '%s' failed: %s", host, err)
 	}
@@ -133,6 +137,7 @@ type UDPConn struct {
 	raddr         *UDPAddr
 	readDeadline  time.Time
 	writeDeadline time.Time
+	sock          *socket
 }
 
 // Use IANA RFC 6335 port range 49152–65535 for ephemeral (dynamic) ports
@@ -155,6 +160,10 @@ func ephemeralPort() int {
 // If the IP field of raddr is nil or an unspecified IP address, the
 // local system is assumed.
 func DialUDP(network string, laddr, raddr *UDPAddr) (*UDPConn, error) {
+	return dialUDP(defaultSocketTrace.Load(), nil, network, laddr, raddr)
+}
+
+func dialUDP(trace *SocketTrace, ctrl controlFunc, network string, laddr, raddr *UDPAddr) (*UDPConn, error) {
 	switch network {
 	case "udp", "udp4":
 	default:
@@ -180,50 +189,79 @@ func DialUDP(network string, laddr, raddr *UDPAddr) (*UDPConn, error) {
 		laddr.Port = ephemeralPort()
 	}
 
+	start := time.Now()
+
 	fd, err := netdev.Socket(_AF_INET, _SOCK_DGRAM, _IPPROTO_UDP)
+	trace.socketCreated(fd, network, err)
 	if err != nil {
+		countDial(start, err)
+		return nil, err
+	}
+	// Registered now so a hung connect shows in OpenSockets
+	sock := newSocket(fd, "UDPConn", network, laddr, raddr, trace)
+
+	if err := runControl(ctrl, fd, raddr.String()); err != nil {
+		sock.close()
+		countDial(start, err)
 		return nil, err
 	}
+
 	lip, _ := netip.AddrFromSlice(laddr.IP)
 	laddrport := netip.AddrPortFrom(lip, uint16(laddr.Port))
 
 	// Local bind
 	err = netdev.Bind(fd, laddrport)
+	trace.bind(fd, laddr, err)
 	if err != nil {
-		netdev.Close(fd)
+		sock.close()
+		countDial(start, err)
 		return nil, err
 	}
 
 	rip, _ := netip.AddrFromSlice(raddr.IP)
 	raddrport := netip.AddrPortFrom(rip, uint16(raddr.Port))
 	// Remote connect
-	if err = netdev.Connect(fd, "", raddrport); err != nil {
-		netdev.Close(fd)
+	trace.connectStart(fd, network, raddr)
+	err = netdev.Connect(fd, "", raddrport)
+	trace.connectDone(fd, network, raddr, err)
+	if err != nil {
+		sock.close()
+		countDial(start, err)
 		return nil, err
 	}
+	countDial(start, nil)
 
 	return &UDPConn{
 		fd:    fd,
 		net:   network,
 		laddr: laddr,
 		raddr: raddr,
+		sock:  sock,
 	}, nil
 }
 
 // SyscallConn returns a raw network connection.
 // This implements the syscall.Conn interface.
 func (c *UDPConn) SyscallConn() (syscall.RawConn, error) {
-	return nil, errors.New("SyscallConn not implemented")
+	if c.sock.closed.Load() {
+		return nil, &OpError{Op: "raw-conn", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	return &rawConn{fd: c.fd, net: c.net, laddr: c.laddr, raddr: c.raddr, sock: c.sock,
+		readDeadline: &c.readDeadline, writeDeadline: &c.writeDeadline}, nil
 }
 
 // TINYGO: Use netdev for Conn methods: Read = Recv, Write = Send, etc.
 
 func (c *UDPConn) Read(b []byte) (int, error) {
+	if err := waitReadable(c.fd, c.readDeadline); err != nil {
+		return 0, &OpError{Op: "read", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
 	n, err := netdev.Recv(c.fd, b, 0, c.readDeadline)
 	// Turn the -1 socket error into 0 and let err speak for error
 	if n < 0 {
 		n = 0
 	}
+	c.sock.countRecv(n, err)
 	if err != nil && err != io.EOF {
 		err = &OpError{Op: "read", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
 	}
@@ -236,6 +274,7 @@ func (c *UDPConn) Write(b []byte) (int, error) {
 	if n < 0 {
 		n = 0
 	}
+	c.sock.countSend(n, err)
 	if err != nil {
 		err = &OpError{Op: "write", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
 	}
@@ -276,8 +315,74 @@ func (c *UDPConn) WriteMsgUDP(b, oob []byte, addr *UDPAddr) (n, oobn int, err er
 	return 0, 0, errors.New("WriteMsgUDP not implemented")
 }
 
+// SetReadBuffer sets the size of the operating system's
+// receive buffer associated with the connection.
+func (c *UDPConn) SetReadBuffer(bytes int) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_RCVBUF, bytes)
+}
+
+// SetWriteBuffer sets the size of the operating system's
+// transmit buffer associated with the connection.
+func (c *UDPConn) SetWriteBuffer(bytes int) error {
+	return c.setSockOpt(_SOL_SOCKET, _SO_SNDBUF, bytes)
+}
+
+func (c *UDPConn) setSockOpt(level, opt int, value any) error {
+	if c.sock.closed.Load() {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: ErrClosed}
+	}
+	if err := netdev.SetSockOpt(c.fd, level, opt, value); err != nil {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+	}
+	return nil
+}
+
+// writeBuffers implements buffersWriter for Buffers.WriteTo.
+//
+// TINYGO: The buffers are sent as one datagram, gathered by the netdev if
+// it supports scatter/gather, otherwise copied into one buffer first.
+func (c *UDPConn) writeBuffers(v *Buffers) (int64, error) {
+	var n int
+	var err error
+	if bs, ok := netdevExt[buffersSender](c.fd); ok {
+		n, err = bs.SendBuffers(c.fd, *v, 0, c.writeDeadline)
+		// Turn the -1 socket error into 0 and let err speak for error
+		if n < 0 {
+			n = 0
+		}
+		c.sock.countSend(n, err)
+		if err != nil {
+			err = &OpError{Op: "write", Net: c.net, Source: c.laddr, Addr: c.raddr, Err: err}
+		}
+	} else {
+		size := 0
+		for _, b := range *v {
+			size += len(b)
+		}
+		var buf []byte
+		if size <= copyBufferSize {
+			p := copyBufferPool.Get().(*[]byte)
+			defer copyBufferPool.Put(p)
+			buf = (*p)[:0]
+		} else {
+			buf = make([]byte, 0, size)
+		}
+		for _, b := range *v {
+			buf = append(buf, b...)
+		}
+		n, err = c.Write(buf)
+	}
+	v.consume(int64(n))
+	return int64(n), err
+}
+
 func (c *UDPConn) Close() error {
-	return netdev.Close(c.fd)
+	return c.sock.close()
+}
+
+// Stats returns the traffic counters of the connection.
+func (c *UDPConn) Stats() ConnStats {
+	return c.sock.connStats()
 }
 
 func (c *UDPConn) LocalAddr() Addr {
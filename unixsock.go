@@ -6,13 +6,26 @@
 
 package net
 
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
 // BUG(mikio): On JS, WASIP1 and Plan 9, methods and functions related
 // to UnixConn and UnixListener are not implemented.
 
 // BUG(mikio): On Windows, methods and functions related to UnixConn
 // and UnixListener don't work for "unixgram" and "unixpacket".
 
-// BUG(paralin): On TinyGo, Unix sockets are not implemented.
+// TINYGO: There is no OS, so Unix domain sockets are in-memory and only
+// connect goroutines of the same program, by name.  Bound names live in a
+// process-wide namespace instead of the file system, and are removed when
+// the socket is closed.  "unix" and "unixpacket" connections are buffered
+// pipes, preserving message boundaries for "unixpacket".
 
 // UnixAddr represents the address of a Unix domain socket end point.
 type UnixAddr struct {
@@ -58,3 +71,488 @@ func ResolveUnixAddr(network, address string) (*UnixAddr, error) {
 		return nil, UnknownNetworkError(network)
 	}
 }
+
+var (
+	errUnixAddrInUse   = errors.New("address already in use")
+	errUnixConnRefused = errors.New("connection refused")
+)
+
+// unixNamespace holds the bound names of Unix sockets: *UnixListener for
+// "unix" and "unixpacket", and *UnixConn for "unixgram".
+var unixNamespace = struct {
+	sync.Mutex
+	names map[string]any
+}{names: make(map[string]any)}
+
+func unixBind(name string, s any) error {
+	unixNamespace.Lock()
+	defer unixNamespace.Unlock()
+	if _, ok := unixNamespace.names[name]; ok {
+		return errUnixAddrInUse
+	}
+	unixNamespace.names[name] = s
+	return nil
+}
+
+func unixUnbind(name string, s any) {
+	unixNamespace.Lock()
+	defer unixNamespace.Unlock()
+	if unixNamespace.names[name] == s {
+		delete(unixNamespace.names, name)
+	}
+}
+
+func unixLookup(name string) any {
+	unixNamespace.Lock()
+	defer unixNamespace.Unlock()
+	return unixNamespace.names[name]
+}
+
+// UnixConn is an implementation of the [Conn] interface for connections
+// to Unix domain sockets.
+type UnixConn struct {
+	ep    *memEndpoint
+	net   string
+	laddr *UnixAddr
+	raddr *UnixAddr
+	bound bool // laddr is bound in unixNamespace
+}
+
+// SyscallConn returns a raw network connection.
+// This implements the [syscall.Conn] interface.
+//
+// TINYGO: In-memory Unix sockets have no raw connection.
+func (c *UnixConn) SyscallConn() (syscall.RawConn, error) {
+	return nil, &OpError{Op: "raw-conn", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: errors.ErrUnsupported}
+}
+
+// CloseRead shuts down the reading side of the Unix domain connection.
+// Most callers should just use Close.
+func (c *UnixConn) CloseRead() error {
+	if c.ep.closed() {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: ErrClosed}
+	}
+	c.ep.rq.closeRead()
+	return nil
+}
+
+// CloseWrite shuts down the writing side of the Unix domain connection.
+// Most callers should just use Close.
+func (c *UnixConn) CloseWrite() error {
+	if c.ep.closed() {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: ErrClosed}
+	}
+	if c.ep.wq == nil {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: errors.ErrUnsupported}
+	}
+	c.ep.wq.closeWrite()
+	return nil
+}
+
+// Read implements the Conn Read method.
+func (c *UnixConn) Read(b []byte) (int, error) {
+	n, _, err := c.ep.read(b)
+	if err != nil && err != io.EOF {
+		err = &OpError{Op: "read", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	return n, err
+}
+
+// Write implements the Conn Write method.
+func (c *UnixConn) Write(b []byte) (int, error) {
+	var n int
+	var err error
+	switch {
+	case c.ep.wq != nil:
+		n, err = c.ep.writeTo(c.ep.wq, b, c.laddr.opAddr())
+	case c.raddr != nil:
+		n, err = c.writeToName(b, c.raddr)
+	default:
+		err = errMissingAddress
+	}
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	return n, err
+}
+
+// writeToName sends datagram b to the "unixgram" socket bound to addr
+func (c *UnixConn) writeToName(b []byte, addr *UnixAddr) (int, error) {
+	peer, ok := unixLookup(addr.Name).(*UnixConn)
+	if !ok || peer.net != "unixgram" {
+		return 0, errUnixConnRefused
+	}
+	return c.ep.writeTo(peer.ep.rq, b, c.laddr.opAddr())
+}
+
+// ReadFromUnix acts like [UnixConn.ReadFrom] but returns a [UnixAddr].
+func (c *UnixConn) ReadFromUnix(b []byte) (int, *UnixAddr, error) {
+	n, from, err := c.ep.read(b)
+	if err != nil && err != io.EOF {
+		err = &OpError{Op: "read", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: err}
+	}
+	addr, _ := from.(*UnixAddr)
+	return n, addr, err
+}
+
+// ReadFrom implements the [PacketConn].ReadFrom method.
+func (c *UnixConn) ReadFrom(b []byte) (int, Addr, error) {
+	n, addr, err := c.ReadFromUnix(b)
+	if addr == nil {
+		return n, nil, err
+	}
+	return n, addr, err
+}
+
+// ReadMsgUnix reads a message from c, copying the payload into b and
+// the associated out-of-band data into oob. It returns the number of
+// bytes copied into b, the number of bytes copied into oob, the flags
+// that were set on the message and the source address of the message.
+//
+// TINYGO: There is no out-of-band data; oobn and flags are always zero.
+func (c *UnixConn) ReadMsgUnix(b, oob []byte) (n, oobn, flags int, addr *UnixAddr, err error) {
+	n, addr, err = c.ReadFromUnix(b)
+	return
+}
+
+// WriteToUnix acts like [UnixConn.WriteTo] but takes a [UnixAddr].
+func (c *UnixConn) WriteToUnix(b []byte, addr *UnixAddr) (int, error) {
+	var n int
+	var err error
+	switch {
+	case c.net != "unixgram" || c.raddr != nil:
+		err = ErrWriteToConnected
+	case addr == nil:
+		err = errMissingAddress
+	default:
+		n, err = c.writeToName(b, addr)
+	}
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: addr.opAddr(), Err: err}
+	}
+	return n, err
+}
+
+// WriteTo implements the [PacketConn].WriteTo method.
+func (c *UnixConn) WriteTo(b []byte, addr Addr) (int, error) {
+	a, ok := addr.(*UnixAddr)
+	if !ok {
+		return 0, &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: addr, Err: &AddrError{Err: "not a unix address", Addr: addr.String()}}
+	}
+	return c.WriteToUnix(b, a)
+}
+
+// WriteMsgUnix writes a message to addr via c, copying the payload
+// from b and the associated out-of-band data from oob. It returns the
+// number of payload and out-of-band bytes written.
+//
+// TINYGO: Out-of-band data is not supported.
+func (c *UnixConn) WriteMsgUnix(b, oob []byte, addr *UnixAddr) (n, oobn int, err error) {
+	if len(oob) > 0 {
+		return 0, 0, &OpError{Op: "write", Net: c.net, Source: c.laddr.opAddr(), Addr: addr.opAddr(), Err: errors.ErrUnsupported}
+	}
+	if addr == nil {
+		n, err = c.Write(b)
+	} else {
+		n, err = c.WriteToUnix(b, addr)
+	}
+	return
+}
+
+// Close closes the connection.
+func (c *UnixConn) Close() error {
+	if !c.ep.close() {
+		return &OpError{Op: "close", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: ErrClosed}
+	}
+	if c.bound {
+		unixUnbind(c.laddr.Name, c)
+	}
+	return nil
+}
+
+// LocalAddr returns the local network address.
+func (c *UnixConn) LocalAddr() Addr {
+	return c.laddr.opAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *UnixConn) RemoteAddr() Addr {
+	return c.raddr.opAddr()
+}
+
+// SetDeadline implements the Conn SetDeadline method.
+func (c *UnixConn) SetDeadline(t time.Time) error {
+	if c.ep.closed() {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: ErrClosed}
+	}
+	c.ep.readDeadline.set(t)
+	c.ep.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline implements the Conn SetReadDeadline method.
+func (c *UnixConn) SetReadDeadline(t time.Time) error {
+	if c.ep.closed() {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: ErrClosed}
+	}
+	c.ep.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline implements the Conn SetWriteDeadline method.
+func (c *UnixConn) SetWriteDeadline(t time.Time) error {
+	if c.ep.closed() {
+		return &OpError{Op: "set", Net: c.net, Source: c.laddr.opAddr(), Addr: c.raddr.opAddr(), Err: ErrClosed}
+	}
+	c.ep.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadBuffer sets the size of the receive buffer associated with the
+// connection.
+//
+// TINYGO: Sizes below 1 byte are raised to 1 byte.
+func (c *UnixConn) SetReadBuffer(bytes int) error {
+	c.ep.rq.setLimit(bytes)
+	return nil
+}
+
+// SetWriteBuffer sets the size of the transmit buffer associated with the
+// connection.
+//
+// TINYGO: The transmit buffer of a connection is the receive buffer of its
+// peer; for "unixgram", SetWriteBuffer has no effect.  Sizes below 1 byte
+// are raised to 1 byte.
+func (c *UnixConn) SetWriteBuffer(bytes int) error {
+	if c.ep.wq != nil {
+		c.ep.wq.setLimit(bytes)
+	}
+	return nil
+}
+
+// DialUnix acts like [Dial] for Unix networks.
+//
+// The network must be a Unix network name; see func Dial for details.
+//
+// If laddr is non-nil, it is used as the local address for the
+// connection.
+func DialUnix(network string, laddr, raddr *UnixAddr) (*UnixConn, error) {
+	switch network {
+	case "unix", "unixgram", "unixpacket":
+	default:
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: raddr.opAddr(), Err: UnknownNetworkError(network)}
+	}
+	if raddr == nil || raddr.Name == "" {
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: nil, Err: errMissingAddress}
+	}
+	var c *UnixConn
+	var err error
+	if network == "unixgram" {
+		c, err = newUnixgram(network, laddr, raddr)
+	} else {
+		c, err = dialUnixStream(network, laddr, raddr)
+	}
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: network, Source: laddr.opAddr(), Addr: raddr.opAddr(), Err: err}
+	}
+	return c, nil
+}
+
+func dialUnixStream(network string, laddr, raddr *UnixAddr) (*UnixConn, error) {
+	l, ok := unixLookup(raddr.Name).(*UnixListener)
+	if !ok || l.net != network {
+		return nil, errUnixConnRefused
+	}
+	if laddr != nil {
+		laddr = &UnixAddr{Name: laddr.Name, Net: network}
+	}
+	raddr = &UnixAddr{Name: raddr.Name, Net: network}
+	cep, sep := newMemPair(network == "unixpacket")
+	client := &UnixConn{ep: cep, net: network, laddr: laddr, raddr: raddr}
+	server := &UnixConn{ep: sep, net: network, laddr: raddr, raddr: laddr}
+	if !l.enqueue(server) {
+		return nil, errUnixConnRefused
+	}
+	return client, nil
+}
+
+// newUnixgram returns a "unixgram" conn, bound to laddr if set and
+// connected to raddr if set.
+func newUnixgram(network string, laddr, raddr *UnixAddr) (*UnixConn, error) {
+	c := &UnixConn{ep: newMemEndpoint(newMemQueue(true), nil), net: network}
+	if laddr != nil && laddr.Name != "" {
+		c.laddr = &UnixAddr{Name: laddr.Name, Net: network}
+		if err := unixBind(laddr.Name, c); err != nil {
+			return nil, err
+		}
+		c.bound = true
+	}
+	if raddr != nil {
+		// Like connect(2), fail if no socket is bound to raddr
+		if peer, ok := unixLookup(raddr.Name).(*UnixConn); !ok || peer.net != "unixgram" {
+			if c.bound {
+				unixUnbind(laddr.Name, c)
+			}
+			return nil, errUnixConnRefused
+		}
+		c.raddr = &UnixAddr{Name: raddr.Name, Net: network}
+	}
+	return c, nil
+}
+
+// UnixListener is a Unix domain socket listener. Clients should
+// typically use variables of type [Listener] instead of assuming Unix
+// domain sockets.
+type UnixListener struct {
+	net      string
+	laddr    *UnixAddr
+	deadline pipeDeadline
+
+	mu     sync.Mutex
+	conns  chan *UnixConn
+	closed bool
+	done   chan struct{}
+}
+
+// ListenUnix acts like [Listen] for Unix networks.
+//
+// The network must be "unix" or "unixpacket".
+func ListenUnix(network string, laddr *UnixAddr) (*UnixListener, error) {
+	switch network {
+	case "unix", "unixpacket":
+	default:
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: UnknownNetworkError(network)}
+	}
+	if laddr == nil || laddr.Name == "" {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: errMissingAddress}
+	}
+	return listenUnix(network, laddr, defaultBacklog)
+}
+
+func listenUnix(network string, laddr *UnixAddr, backlog int) (*UnixListener, error) {
+	l := &UnixListener{
+		net:      network,
+		laddr:    &UnixAddr{Name: laddr.Name, Net: network},
+		deadline: makePipeDeadline(),
+		conns:    make(chan *UnixConn, backlog),
+		done:     make(chan struct{}),
+	}
+	if err := unixBind(laddr.Name, l); err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr, Err: err}
+	}
+	return l, nil
+}
+
+// enqueue queues c for Accept.  It fails if the listener is closed or its
+// backlog is full.
+func (l *UnixListener) enqueue(c *UnixConn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	select {
+	case l.conns <- c:
+		return true
+	default:
+		return false
+	}
+}
+
+// AcceptUnix accepts the next incoming call and returns the new
+// connection.
+func (l *UnixListener) AcceptUnix() (*UnixConn, error) {
+	if isClosedChan(l.done) {
+		return nil, &OpError{Op: "accept", Net: l.net, Source: nil, Addr: l.laddr, Err: ErrClosed}
+	}
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, &OpError{Op: "accept", Net: l.net, Source: nil, Addr: l.laddr, Err: ErrClosed}
+	case <-l.deadline.wait():
+		return nil, &OpError{Op: "accept", Net: l.net, Source: nil, Addr: l.laddr, Err: os.ErrDeadlineExceeded}
+	}
+}
+
+// Accept implements the Accept method in the [Listener] interface.
+// Returned connections will be of type [*UnixConn].
+func (l *UnixListener) Accept() (Conn, error) {
+	c, err := l.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close stops listening on the Unix address. Already accepted
+// connections are not closed.
+func (l *UnixListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return &OpError{Op: "close", Net: l.net, Source: nil, Addr: l.laddr, Err: ErrClosed}
+	}
+	l.closed = true
+	close(l.done)
+	l.mu.Unlock()
+
+	unixUnbind(l.laddr.Name, l)
+	// Refuse the connections not yet accepted
+	for {
+		select {
+		case c := <-l.conns:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Addr returns the listener's network address.
+// The Addr returned is shared by all invocations of Addr, so
+// do not modify it.
+func (l *UnixListener) Addr() Addr { return l.laddr }
+
+// SetDeadline sets the deadline associated with the listener.
+// A zero time value disables the deadline.
+func (l *UnixListener) SetDeadline(t time.Time) error {
+	if isClosedChan(l.done) {
+		return &OpError{Op: "set", Net: l.net, Source: nil, Addr: l.laddr, Err: ErrClosed}
+	}
+	l.deadline.set(t)
+	return nil
+}
+
+// SetUnlinkOnClose sets whether the underlying socket file should be removed
+// from the file system when the listener is closed.
+//
+// TINYGO: The name is always removed from the namespace on Close.
+func (l *UnixListener) SetUnlinkOnClose(unlink bool) {}
+
+// SyscallConn returns a raw network connection.
+// This implements the [syscall.Conn] interface.
+//
+// TINYGO: In-memory Unix sockets have no raw connection.
+func (l *UnixListener) SyscallConn() (syscall.RawConn, error) {
+	return nil, &OpError{Op: "raw-conn", Net: l.net, Source: nil, Addr: l.laddr, Err: errors.ErrUnsupported}
+}
+
+// ListenUnixgram acts like [ListenPacket] for Unix networks.
+//
+// The network must be "unixgram".
+func ListenUnixgram(network string, laddr *UnixAddr) (*UnixConn, error) {
+	switch network {
+	case "unixgram":
+	default:
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr.opAddr(), Err: UnknownNetworkError(network)}
+	}
+	if laddr == nil || laddr.Name == "" {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: errMissingAddress}
+	}
+	c, err := newUnixgram(network, laddr, nil)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: laddr, Err: err}
+	}
+	return c, nil
+}
@@ -0,0 +1,210 @@
+// In-memory Unix domain socket tests
+
+package net
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUnixStream(t *testing.T) {
+	ln, err := ListenUnix("unix", &UnixAddr{Name: "/test/stream", Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := DialUnix("unix", nil, &UnixAddr{Name: "/test/stream", Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := ln.AcceptUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if got := c.RemoteAddr().String(); got != "/test/stream" {
+		t.Errorf("client RemoteAddr() = %s, want /test/stream", got)
+	}
+	if got := s.LocalAddr().String(); got != "/test/stream" {
+		t.Errorf("server LocalAddr() = %s, want /test/stream", got)
+	}
+
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	c.CloseWrite()
+	b, err := io.ReadAll(s)
+	if err != nil || string(b) != "ping" {
+		t.Fatalf("server read %q, %v, want %q, nil", b, err, "ping")
+	}
+	if _, err := s.Write([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	b = make([]byte, 8)
+	n, err := c.Read(b)
+	if err != nil || string(b[:n]) != "pong" {
+		t.Errorf("client read %q, %v, want %q, nil", b[:n], err, "pong")
+	}
+}
+
+func TestUnixPacket(t *testing.T) {
+	ln, err := ListenUnix("unixpacket", &UnixAddr{Name: "/test/packet", Net: "unixpacket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := DialUnix("unixpacket", nil, &UnixAddr{Name: "/test/packet", Net: "unixpacket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := ln.AcceptUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c.Write([]byte("one"))
+	c.Write([]byte("two"))
+	b := make([]byte, 16)
+	for _, want := range []string{"one", "two"} {
+		n, err := s.Read(b)
+		if err != nil || string(b[:n]) != want {
+			t.Errorf("read %q, %v, want %q, nil", b[:n], err, want)
+		}
+	}
+}
+
+func TestUnixgram(t *testing.T) {
+	srv, err := ListenUnixgram("unixgram", &UnixAddr{Name: "/test/gram", Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	c, err := DialUnix("unixgram", &UnixAddr{Name: "/test/gram-client", Net: "unixgram"}, &UnixAddr{Name: "/test/gram", Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 16)
+	n, from, err := srv.ReadFromUnix(b)
+	if err != nil || string(b[:n]) != "hello" {
+		t.Fatalf("ReadFromUnix = %q, %v, want %q, nil", b[:n], err, "hello")
+	}
+	if from == nil || from.Name != "/test/gram-client" {
+		t.Fatalf("ReadFromUnix from %v, want /test/gram-client", from)
+	}
+	if _, err := srv.WriteToUnix([]byte("reply"), from); err != nil {
+		t.Fatal(err)
+	}
+	n, err = c.Read(b)
+	if err != nil || string(b[:n]) != "reply" {
+		t.Errorf("Read = %q, %v, want %q, nil", b[:n], err, "reply")
+	}
+
+	if _, err := c.WriteToUnix([]byte("x"), from); !errors.Is(err, ErrWriteToConnected) {
+		t.Errorf("WriteToUnix on connected conn = %v, want %v", err, ErrWriteToConnected)
+	}
+}
+
+var unixDialErrorTests = []struct {
+	network string
+	laddr   *UnixAddr
+	raddr   *UnixAddr
+	err     error
+}{
+	{"unix", nil, &UnixAddr{Name: "/test/none", Net: "unix"}, errUnixConnRefused},
+	{"unixpacket", nil, &UnixAddr{Name: "/test/none", Net: "unixpacket"}, errUnixConnRefused},
+	{"unixgram", nil, &UnixAddr{Name: "/test/none", Net: "unixgram"}, errUnixConnRefused},
+	{"unixgram", &UnixAddr{Name: "/test/gram-local", Net: "unixgram"}, &UnixAddr{Name: "/test/none", Net: "unixgram"}, errUnixConnRefused},
+	{"unix", nil, nil, errMissingAddress},
+}
+
+func TestUnixDialErrors(t *testing.T) {
+	for i, tt := range unixDialErrorTests {
+		c, err := DialUnix(tt.network, tt.laddr, tt.raddr)
+		if err == nil {
+			c.Close()
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("#%d: DialUnix(%q, %v, %v) = %v, want %v", i, tt.network, tt.laddr, tt.raddr, err, tt.err)
+		}
+	}
+	// A failed dial leaves its local name unbound
+	if unixLookup("/test/gram-local") != nil {
+		t.Errorf("failed unixgram dial left its local name bound")
+	}
+}
+
+func TestUnixAddrInUse(t *testing.T) {
+	ln, err := ListenUnix("unix", &UnixAddr{Name: "/test/inuse", Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix("unix", &UnixAddr{Name: "/test/inuse", Net: "unix"}); !errors.Is(err, errUnixAddrInUse) {
+		t.Errorf("second ListenUnix = %v, want %v", err, errUnixAddrInUse)
+	}
+	ln.Close()
+
+	// Close removes the name
+	ln, err = ListenUnix("unix", &UnixAddr{Name: "/test/inuse", Net: "unix"})
+	if err != nil {
+		t.Fatalf("ListenUnix after Close = %v", err)
+	}
+	ln.Close()
+	if _, err := DialUnix("unix", nil, &UnixAddr{Name: "/test/inuse", Net: "unix"}); !errors.Is(err, errUnixConnRefused) {
+		t.Errorf("DialUnix to closed listener = %v, want %v", err, errUnixConnRefused)
+	}
+}
+
+func TestUnixListenerDeadline(t *testing.T) {
+	ln, err := ListenUnix("unix", &UnixAddr{Name: "/test/deadline", Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ln.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := ln.Accept(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Accept = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+}
+
+func TestUnixSetReadBufferZero(t *testing.T) {
+	ln, err := ListenUnix("unix", &UnixAddr{Name: "/test/rcvbuf", Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c, err := DialUnix("unix", nil, &UnixAddr{Name: "/test/rcvbuf", Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := ln.AcceptUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.SetReadBuffer(0); err != nil {
+		t.Fatal(err)
+	}
+	go io.Copy(io.Discard, s)
+	c.SetWriteDeadline(time.Now().Add(time.Second))
+	if n, err := c.Write([]byte("data")); n != 4 || err != nil {
+		t.Errorf("Write to zero read buffer = %d, %v, want 4, nil", n, err)
+	}
+}
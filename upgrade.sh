@@ -58,6 +58,37 @@ error()   { echo -e "${RED}[ERROR]${NC} $*" >&2; }
 TINYGO_ONLY_FILES=(
     "netdev.go"
     "tlssock.go"
+    "sockets.go"
+    "http/netdebug/netdebug.go"
+    "stats.go"
+    "trace.go"
+    "netlog/netlog.go"
+    "ratelimit/ratelimit.go"
+    "ratelimit/ratelimit_test.go"
+    "tcpsock_test.go"
+    "poll.go"
+    "rawconn_netdev.go"
+    "ping.go"
+    "ping/ping.go"
+    "mempipe.go"
+    "mempipe_test.go"
+    "unixsock_test.go"
+    "pipelistener.go"
+    "pipelistener_test.go"
+    "http/softtls.go"
+    "socks/socks.go"
+    "socks/socks_test.go"
+    "link.go"
+    "failover.go"
+    "failover_test.go"
+    "interface_netdev.go"
+    "http/softtls_test.go"
+    "tlssock_test.go"
+    "poll_test.go"
+    "sockets_test.go"
+    "iprawsock_test.go"
+    "trace_test.go"
+    "ping/ping_test.go"
     "README.md"
     "LICENSE"
 )